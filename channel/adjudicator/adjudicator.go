@@ -2,28 +2,351 @@ package adjudicator
 
 import (
 	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	solchannel "github.com/perun-network/perun-solana-backend/channel"
+	"github.com/perun-network/perun-solana-backend/client"
+	"github.com/perun-network/perun-solana-backend/encoding"
 
 	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+)
+
+const (
+	MaxIterationsUntilRegistered = 30
+	DefaultPollingInterval       = time.Duration(4) * time.Second
+)
+
+var _ channel.Adjudicator = (*Adjudicator)(nil)
+
+// Adjudicator is a struct that implements the Adjudicator interface for Solana.
+type Adjudicator struct {
+	cb              *client.ContractBackend
+	perunAddr       solana.PublicKey
+	maxIters        int
+	pollingInterval time.Duration
+	oneWithdrawer   bool
+	subCfg          SubscriptionConfig
+}
+
+// NewAdjudicator creates a new Adjudicator instance with the given parameters. If oneWithdrawer is set, a single
+// withdrawal pays out both participants.
+func NewAdjudicator(cb *client.ContractBackend, perunAddr solana.PublicKey, oneWithdrawer bool) *Adjudicator {
+	return &Adjudicator{
+		cb:              cb,
+		perunAddr:       perunAddr,
+		maxIters:        MaxIterationsUntilRegistered,
+		pollingInterval: DefaultPollingInterval,
+		oneWithdrawer:   oneWithdrawer,
+		subCfg:          SubscriptionConfigFromCluster(cb.ClusterConfig()),
+	}
+}
+
+// NewAdjudicatorFromCluster creates a new Adjudicator for the Perun program and with the polling interval of the
+// cluster config of the given contract backend.
+func NewAdjudicatorFromCluster(cb *client.ContractBackend, oneWithdrawer bool) (*Adjudicator, error) {
+	cluster := cb.ClusterConfig()
+	if cluster.PerunProgramID.IsZero() {
+		return nil, errors.New("cluster config does not set the Perun program ID")
+	}
+	a := NewAdjudicator(cb, cluster.PerunProgramID, oneWithdrawer)
+	a.pollingInterval = time.Duration(cluster.Timeouts.PollingInterval)
+	a.subCfg.PollingInterval = a.pollingInterval
+	return a, nil
+}
+
+// SetSubscriptionConfig sets the configuration used for subscriptions created by Subscribe.
+func (a *Adjudicator) SetSubscriptionConfig(cfg SubscriptionConfig) {
+	a.subCfg = cfg
+}
+
+// GetPerunAddr returns the perun address of the adjudicator.
+func (a *Adjudicator) GetPerunAddr() solana.PublicKey {
+	return a.perunAddr
+}
+
+// RegisterAction describes the on-chain action taken by a registration.
+type RegisterAction string
+
+const (
+	// RegisterActionRegistered means that the channel was not disputed before and the state was registered.
+	RegisterActionRegistered RegisterAction = "registered"
+	// RegisterActionRefuted means that an older state was registered before and was refuted with the newer state.
+	RegisterActionRefuted RegisterAction = "refuted"
+	// RegisterActionAlreadyCurrent means that a state with at least the same version was already registered, so
+	// nothing was submitted.
+	RegisterActionAlreadyCurrent RegisterAction = "already-current"
 )
 
-type Adjudicator struct{}
+// Register registers the state of the given request on-chain. See RegisterState.
+func (a *Adjudicator) Register(ctx context.Context, req channel.AdjudicatorReq, subChannels []channel.SignedState) error {
+	_, err := a.RegisterState(ctx, req, subChannels)
+	return err
+}
+
+// RegisterState registers the state of the given request on-chain and reports the action taken. A dispute is only
+// submitted if the on-chain channel is not disputed yet or disputed with an older version, in which case the dispute
+// refutes the registered state. The sub-channels and virtual channels the state locks funds in are disputed together
+// with it, so subChannels must contain a signed state for each of them. A virtual channel is registered by registering
+// its ledger parents. It returns once the channel is disputed on-chain with a version at least as high as the one of
+// the submitted state.
+func (a *Adjudicator) RegisterState(ctx context.Context, req channel.AdjudicatorReq, subChannels []channel.SignedState) (RegisterAction, error) {
+	log.Println("Register called")
+	state := req.Tx.State
+	if state == nil {
+		return "", errors.New("request does not contain a state")
+	}
+	if req.Params == nil || !req.Params.LedgerChannel {
+		return "", errors.New("only ledger channels can be registered, sub-channels and virtual channels are registered with their parents")
+	}
+	if err := checkSubChannels(state, subChannels); err != nil {
+		return "", err
+	}
+
+	chanInfo, err := a.cb.GetChannelInfo(ctx, a.perunAddr, state.ID)
+	if err != nil {
+		return "", errors.Join(errors.New("error while getting channel info"), err)
+	}
+	action, err := a.registerAction(ctx, chanInfo, state.Version)
+	if err != nil || action == RegisterActionAlreadyCurrent {
+		log.Println("Register action: ", action)
+		return action, err
+	}
+
+	if err := a.cb.Dispute(ctx, a.perunAddr, state, req.Tx.Sigs, subChannels); err != nil {
+		return "", errors.Join(errors.New("error while disputing channel"), err)
+	}
+	if err := a.waitForDisputed(ctx, state.ID, state.Version); err != nil {
+		return "", err
+	}
+	log.Println("Register action: ", action)
+	return action, nil
+}
+
+// registerAction determines the action needed to register the given version based on the on-chain channel.
+func (a *Adjudicator) registerAction(ctx context.Context, chanInfo encoding.Channel, version uint64) (RegisterAction, error) {
+	control := chanInfo.Control
+	switch {
+	case (control.Disputed || control.Closed) && chanInfo.State.Version >= version:
+		return RegisterActionAlreadyCurrent, nil
+	case control.Closed:
+		return "", errors.New("channel already closed with an older version")
+	case !control.Disputed:
+		return RegisterActionRegistered, nil
+	}
+
+	timeout := NewTimeout(a.cb, control.Timestamp, chanInfo.Params.ChallengeDuration)
+	if timeout.IsElapsed(ctx) {
+		return "", errors.New("challenge duration of registered older version already passed")
+	}
+	return RegisterActionRefuted, nil
+}
+
+// waitForDisputed polls the channel until it is disputed with at least the given version.
+func (a *Adjudicator) waitForDisputed(ctx context.Context, id channel.ID, version uint64) error {
+	for i := 0; i < a.maxIters; i++ {
+		chanInfo, err := a.cb.GetChannelInfo(ctx, a.perunAddr, id)
+		if err != nil {
+			log.Println("Error while polling for disputed channel: ", err)
+		} else if chanInfo.Control.Disputed && chanInfo.State.Version >= version {
+			log.Println("Channel disputed with version: ", chanInfo.State.Version)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.pollingInterval):
+		}
+	}
+	return errors.New("channel not disputed after max iterations")
+}
+
+// Withdraw concludes the channel of the given request if necessary and withdraws the funds of the requesting party.
+// A final state with both signatures is closed cooperatively, a disputed channel is force closed once its challenge
+// duration has passed. When force closing, the funds locked in sub-channels and virtual channels are settled into the
+// channel according to their registered states, so stateMap must contain the state of each of them. Steps that are
+// already reflected on-chain are skipped, so Withdraw can safely be called again.
+func (a *Adjudicator) Withdraw(ctx context.Context, req channel.AdjudicatorReq, stateMap channel.StateMap) error {
+	log.Println("Withdraw called")
+	if req.Idx != 0 && req.Idx != 1 {
+		return errors.New("req.Idx must be 0 or 1")
+	}
+	state := req.Tx.State
+	if state == nil {
+		return errors.New("request does not contain a state")
+	}
+	if req.Params == nil || !req.Params.LedgerChannel {
+		return errors.New("only ledger channels can be withdrawn, sub-channels and virtual channels are settled into their parents")
+	}
+
+	chanInfo, err := a.cb.GetChannelInfo(ctx, a.perunAddr, state.ID)
+	if err != nil {
+		return errors.Join(errors.New("error while getting channel info"), err)
+	}
+	if !chanInfo.Control.Closed {
+		if err := a.conclude(ctx, req, chanInfo, stateMap); err != nil {
+			return err
+		}
+	}
+	if a.isWithdrawn(chanInfo.Control, req.Idx) {
+		log.Println("Channel already withdrawn")
+		return nil
+	}
+
+	err = a.cb.Withdraw(ctx, a.perunAddr, state.ID, req.Idx == 1, a.oneWithdrawer)
+	if err == nil {
+		return nil
+	}
+	// The channel might have been withdrawn concurrently by the other party.
+	chanInfo, errInfo := a.cb.GetChannelInfo(ctx, a.perunAddr, state.ID)
+	if errInfo == nil && a.isWithdrawn(chanInfo.Control, req.Idx) {
+		log.Println("Channel already withdrawn")
+		return nil
+	}
+	return errors.Join(errors.New("error while withdrawing from channel"), err)
+}
+
+// Progress progresses a disputed app channel to the new state of the given request. The transition is validated
+// on-chain by the app program of the channel.
+func (a *Adjudicator) Progress(ctx context.Context, req channel.ProgressReq) error {
+	log.Println("Progress called")
+	if req.NewState == nil {
+		return errors.New("request does not contain a new state")
+	}
+	if req.Idx != 0 && req.Idx != 1 {
+		return errors.New("req.Idx must be 0 or 1")
+	}
+	if req.NewState.App == nil || channel.IsNoApp(req.NewState.App) {
+		return errors.New("progress is only supported for app channels")
+	}
+	appID, err := solchannel.ToAppID(req.NewState.App)
+	if err != nil {
+		return err
+	}
+
+	chanInfo, err := a.cb.GetChannelInfo(ctx, a.perunAddr, req.NewState.ID)
+	if err != nil {
+		return errors.Join(errors.New("error while getting channel info"), err)
+	}
+	if !chanInfo.Control.Disputed || chanInfo.Control.Closed {
+		return errors.New("channel must be disputed and not closed to progress")
+	}
+	if chanInfo.State.Version >= req.NewState.Version {
+		return errors.New("on-chain state is already at least as new as the progressed state")
+	}
+
+	if err := a.cb.Progress(ctx, a.perunAddr, appID.ProgramID, req.NewState, req.Idx == 1, req.Sig); err != nil {
+		return errors.Join(errors.New("error while progressing channel"), err)
+	}
+	return a.waitForDisputed(ctx, req.NewState.ID, req.NewState.Version)
+}
+
+// Subscribe returns a subscription to the adjudicator events of the channel with the given ID. The event source is
+// selected by the subscription configuration of the adjudicator.
+func (a *Adjudicator) Subscribe(ctx context.Context, id channel.ID) (channel.AdjudicatorSubscription, error) {
+	switch a.subCfg.Source {
+	case PollingSource:
+		return NewAdjudicatorSubFromChannelID(ctx, a.cb, a.perunAddr, id, a.subCfg), nil
+	case WebSocketSource:
+		return NewWSAdjudicatorSub(ctx, a.cb, a.perunAddr, id, a.subCfg), nil
+	default:
+		return nil, errors.New("unknown subscription source")
+	}
+}
+
+// conclude closes the channel of the given request, either cooperatively or by force.
+func (a *Adjudicator) conclude(ctx context.Context, req channel.AdjudicatorReq, chanInfo encoding.Channel, stateMap channel.StateMap) error {
+	state := req.Tx.State
+	var err error
+	switch {
+	case state.IsFinal && hasAllSigs(req.Tx.Sigs):
+		err = a.cb.Close(ctx, a.perunAddr, state, req.Tx.Sigs)
+	case chanInfo.Control.Disputed:
+		subIDs, errSub := subChannelIDs(state, stateMap)
+		if errSub != nil {
+			return errSub
+		}
+		timeout := NewTimeout(a.cb, chanInfo.Control.Timestamp, chanInfo.Params.ChallengeDuration)
+		if err := timeout.Wait(ctx); err != nil {
+			return err
+		}
+		err = a.cb.ForceClose(ctx, a.perunAddr, state.ID, subIDs)
+	default:
+		return errors.New("channel is neither final nor disputed")
+	}
+	if err == nil {
+		return nil
+	}
 
-func NewAdjudicator() *Adjudicator {
-	return &Adjudicator{}
+	// The channel might have been concluded concurrently by the other party.
+	chanInfo, errInfo := a.cb.GetChannelInfo(ctx, a.perunAddr, state.ID)
+	if errInfo == nil && chanInfo.Control.Closed {
+		return nil
+	}
+	return errors.Join(errors.New("error while concluding channel"), err)
 }
 
-func (a Adjudicator) Register(ctx context.Context, req channel.AdjudicatorReq, subChannels []channel.SignedState) error {
-	panic("TODO: implement Register in adjudicator")
+// isWithdrawn returns whether the withdrawal of the given party is already reflected in the on-chain control.
+func (a *Adjudicator) isWithdrawn(control encoding.Control, idx channel.Index) bool {
+	if a.oneWithdrawer {
+		return control.WithdrawnA && control.WithdrawnB
+	}
+	if idx == 1 {
+		return control.WithdrawnB
+	}
+	return control.WithdrawnA
 }
 
-func (a Adjudicator) Withdraw(ctx context.Context, req channel.AdjudicatorReq, stateMap channel.StateMap) error {
-	panic("TODO: implement Withdraw in adjudicator")
+// checkSubChannels checks that the given sub-channels contain a signed state for each sub-allocation the given state
+// locks funds in. Sub-channels of sub-channels may be contained as well.
+func checkSubChannels(state *channel.State, subChannels []channel.SignedState) error {
+	subStates := make(map[channel.ID]bool, len(subChannels))
+	for _, sub := range subChannels {
+		if sub.Params == nil || sub.State == nil {
+			return errors.New("sub-channel does not contain params or state")
+		}
+		subStates[sub.State.ID] = true
+	}
+	for _, subAlloc := range state.Locked {
+		if !subStates[subAlloc.ID] {
+			return errors.New("missing state of sub-channel with locked funds")
+		}
+	}
+	return nil
 }
 
-func (a Adjudicator) Progress(ctx context.Context, req channel.ProgressReq) error {
-	return nil // Only used in AppChannel
+// subChannelIDs returns the IDs of all sub-channels and virtual channels the given state locks funds in, including
+// nested ones, using the states of the given state map.
+func subChannelIDs(state *channel.State, stateMap channel.StateMap) ([]channel.ID, error) {
+	var ids []channel.ID
+	for _, subAlloc := range state.Locked {
+		subState, ok := stateMap[subAlloc.ID]
+		if !ok {
+			return nil, errors.New("missing state of sub-channel with locked funds")
+		}
+		nested, err := subChannelIDs(subState, stateMap)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, subAlloc.ID)
+		ids = append(ids, nested...)
+	}
+	return ids, nil
 }
 
-func (a Adjudicator) Subscribe(ctx context.Context, id channel.ID) (channel.AdjudicatorSubscription, error) {
-	return NewAdjudicatorSubFromChannelID(ctx, id), nil
+// hasAllSigs returns whether the given signatures contain a signature of both participants.
+func hasAllSigs(sigs []wallet.Sig) bool {
+	if len(sigs) != 2 { //nolint:gomnd
+		return false
+	}
+	for _, sig := range sigs {
+		if sig == nil {
+			return false
+		}
+	}
+	return true
 }
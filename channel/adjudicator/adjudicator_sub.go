@@ -2,31 +2,346 @@ package adjudicator
 
 import (
 	"context"
+	"errors"
+	"log"
+	"sync"
 	"time"
 
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/perun-network/perun-solana-backend/client"
+	"github.com/perun-network/perun-solana-backend/encoding"
+
 	"perun.network/go-perun/channel"
 )
 
 const (
 	DefaultBufferSize                  = 3
 	DefaultSubscriptionPollingInterval = time.Duration(4) * time.Second
+	MaxConsecutivePollingErrors        = 10
 )
 
-type PollingSubscription struct{} //TODO
+var _ channel.AdjudicatorSubscription = (*PollingSubscription)(nil)
+
+// PollingSubscription is an AdjudicatorSubscription that polls the on-chain channel account and derives adjudicator
+// events from the changes between two polls.
+type PollingSubscription struct {
+	subscription
+	pollInterval time.Duration
+}
+
+// NewAdjudicatorSubFromChannelID creates a new PollingSubscription for the channel with the given ID and starts
+// polling in the configured interval. The context is not used for the lifetime of the subscription, which ends with
+// Close.
+func NewAdjudicatorSubFromChannelID(ctx context.Context, cb *client.ContractBackend, perunAddr solana.PublicKey, id channel.ID, cfg SubscriptionConfig) *PollingSubscription {
+	cfg = cfg.withDefaults()
+	subCtx, cancel := context.WithCancel(context.Background())
+	sub := &PollingSubscription{
+		subscription: newSubscription(cb, perunAddr, id, cfg, cancel),
+		pollInterval: cfg.PollingInterval,
+	}
+	go func() {
+		defer close(sub.done)
+		if sub.replayHistory {
+			sub.replay(subCtx)
+		}
+		sub.pollLoop(subCtx, sub.pollInterval)
+	}()
+	return sub
+}
+
+// subscription holds the state shared by the different adjudicator event sources.
+type subscription struct {
+	cb            *client.ContractBackend
+	perunAddr     solana.PublicKey
+	cid           channel.ID
+	replayHistory bool
+	tracker       eventTracker
+	events        chan channel.AdjudicatorEvent
+	done          chan struct{}
+	cancel        context.CancelFunc
+	closeOnce     sync.Once
+	errMtx        sync.Mutex
+	err           error
+}
 
-func NewAdjudicatorSubFromChannelID(ctx context.Context, id channel.ID) *PollingSubscription {
-	return &PollingSubscription{}
+func newSubscription(cb *client.ContractBackend, perunAddr solana.PublicKey, id channel.ID, cfg SubscriptionConfig, cancel context.CancelFunc) subscription {
+	return subscription{
+		cb:            cb,
+		perunAddr:     perunAddr,
+		cid:           id,
+		replayHistory: cfg.ReplayHistory,
+		tracker:       eventTracker{cb: cb, perunAddr: perunAddr, cid: id},
+		events:        make(chan channel.AdjudicatorEvent, DefaultBufferSize),
+		done:          make(chan struct{}),
+		cancel:        cancel,
+	}
 }
 
-func (p *PollingSubscription) Next() channel.AdjudicatorEvent {
-	//TODO: implement Next in adjudicator subscription
+// Next returns the next adjudicator event. It blocks until an event is available and returns nil if the
+// subscription is closed or failed.
+func (s *subscription) Next() channel.AdjudicatorEvent {
+	select {
+	case ev := <-s.events:
+		return ev
+	case <-s.done:
+		select {
+		case ev := <-s.events:
+			return ev
+		default:
+			return nil
+		}
+	}
+}
+
+// Err returns the error that terminated the subscription, if any.
+func (s *subscription) Err() error {
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	return s.err
+}
+
+// Close stops the subscription and waits for its goroutine to return.
+func (s *subscription) Close() error {
+	s.closeOnce.Do(s.cancel)
+	<-s.done
 	return nil
 }
 
-func (p *PollingSubscription) Err() error {
-	return nil //TODO: implement Err in adjudicator subscription
+// replay emits the events of the on-chain history of the channel, so that no event is missed that happened before
+// the subscription was created. Failing to read the history is logged, the subscription then continues with the
+// current state of the channel only.
+func (s *subscription) replay(ctx context.Context) {
+	history, err := s.cb.GetChannelHistory(ctx, s.perunAddr, s.cid)
+	if err != nil {
+		log.Println("Error while replaying channel history: ", err)
+		return
+	}
+	var params *encoding.Params
+	for _, ev := range history {
+		if ev.Type == client.ChannelOpened {
+			params = ev.Params
+			break
+		}
+	}
+	// The channel stored on-chain provides the parameters if the opening transaction is no longer available and the
+	// timestamp of the latest dispute for events without block time.
+	var disputeTimestamp uint64
+	chanInfo, err := s.cb.GetChannelInfo(ctx, s.perunAddr, s.cid)
+	switch {
+	case err == nil:
+		disputeTimestamp = chanInfo.Control.Timestamp
+		if params == nil {
+			params = &chanInfo.Params
+		}
+	case params == nil:
+		log.Println("Error while getting channel parameters for replay: ", err)
+		return
+	}
+	s.emit(ctx, s.tracker.replay(ctx, history, *params, disputeTimestamp))
+}
+
+// pollLoop polls the channel in the given interval until the context is done, the channel account is removed or
+// polling fails terminally.
+func (s *subscription) pollLoop(ctx context.Context, interval time.Duration) {
+	errCount := 0
+	for {
+		stop, err := s.poll(ctx)
+		switch {
+		case err == nil:
+			errCount = 0
+		case errors.Is(err, client.ErrCouldNotDecodeTx):
+			s.setErr(err)
+			return
+		default:
+			errCount++
+			log.Println("Error while polling channel: ", err)
+			if errCount >= MaxConsecutivePollingErrors {
+				s.setErr(errors.Join(errors.New("polling channel failed repeatedly"), err))
+				return
+			}
+		}
+		if stop {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// poll fetches the channel once and emits the resulting events. It reports whether the subscription should stop
+// because the channel account no longer exists.
+func (s *subscription) poll(ctx context.Context) (bool, error) {
+	chanInfo, err := s.cb.GetChannelInfo(ctx, s.perunAddr, s.cid)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return s.handleRemoved(ctx), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	s.emit(ctx, s.tracker.update(ctx, chanInfo))
+	return false, nil
+}
+
+// handleRemoved handles a missing channel account and reports whether the subscription should stop.
+func (s *subscription) handleRemoved(ctx context.Context) bool {
+	if !s.tracker.seen {
+		return false // Channel not opened yet.
+	}
+	// The channel account is removed once all funds are withdrawn.
+	s.emit(ctx, s.tracker.remove())
+	return true
+}
+
+func (s *subscription) emit(ctx context.Context, events []channel.AdjudicatorEvent) {
+	for _, ev := range events {
+		select {
+		case s.events <- ev:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *subscription) setErr(err error) {
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	s.err = err
+}
+
+// eventTracker derives adjudicator events from consecutive snapshots of an on-chain channel.
+type eventTracker struct {
+	cb        *client.ContractBackend
+	perunAddr solana.PublicKey
+	cid       channel.ID
+	seen      bool
+	disputed  bool
+	closed    bool
+	version   uint64
+}
+
+// update records the given snapshot and returns the events it implies. On the first snapshot only the most recent
+// past event is returned. A newer version of a disputed app channel is reported as progressed.
+func (t *eventTracker) update(ctx context.Context, chanInfo encoding.Channel) []channel.AdjudicatorEvent {
+	control := chanInfo.Control
+	version := chanInfo.State.Version
+	var events []channel.AdjudicatorEvent
+
+	registered := control.Disputed && (!t.disputed || version > t.version)
+	progressed := registered && t.disputed && !chanInfo.Params.App.IsZero()
+	concluded := control.Closed && !t.closed
+	if registered && !(concluded && !t.seen) {
+		timeout := NewTimeout(t.cb, control.Timestamp, chanInfo.Params.ChallengeDuration)
+		if progressed {
+			if ev := t.progressedEvent(ctx, chanInfo.Params, chanInfo.State, timeout, t.lastActor(ctx, version)); ev != nil {
+				events = append(events, ev)
+			}
+		} else {
+			events = append(events, channel.NewRegisteredEvent(t.cid, timeout, version, nil, nil))
+		}
+	}
+	if concluded {
+		events = append(events, channel.NewConcludedEvent(t.cid, &channel.ElapsedTimeout{}, version))
+	}
+
+	t.seen = true
+	t.disputed = control.Disputed
+	t.closed = control.Closed
+	t.version = version
+	return events
+}
+
+// replay records the given history of the channel with the given parameters and returns the events it implies.
+// Events without block time use the given timestamp of the latest on-chain dispute instead.
+func (t *eventTracker) replay(ctx context.Context, history []client.ChannelEvent, params encoding.Params, disputeTimestamp uint64) []channel.AdjudicatorEvent {
+	var events []channel.AdjudicatorEvent
+	for _, ev := range history {
+		t.seen = true
+		switch ev.Type {
+		case client.ChannelDisputed:
+			if t.closed {
+				continue
+			}
+			timeout := NewTimeout(t.cb, eventTimestamp(ev, disputeTimestamp), params.ChallengeDuration)
+			events = append(events, channel.NewRegisteredEvent(t.cid, timeout, ev.Version(), nil, nil))
+			t.disputed = true
+			t.version = ev.Version()
+		case client.ChannelProgressed:
+			if t.closed {
+				continue
+			}
+			timeout := NewTimeout(t.cb, eventTimestamp(ev, disputeTimestamp), params.ChallengeDuration)
+			if progressed := t.progressedEvent(ctx, params, *ev.State, timeout, partyIndex(ev.PartyIdx)); progressed != nil {
+				events = append(events, progressed)
+			}
+			t.version = ev.Version()
+		case client.ChannelClosed, client.ChannelForceClosed:
+			if t.closed {
+				continue
+			}
+			if ev.State != nil {
+				t.version = ev.Version()
+			}
+			events = append(events, channel.NewConcludedEvent(t.cid, &channel.ElapsedTimeout{}, t.version))
+			t.closed = true
+		}
+	}
+	return events
+}
+
+// progressedEvent returns the event for the progression of the channel to the given on-chain state, or nil if the
+// state cannot be decoded, for example because the app is not registered. go-perun needs the state to follow the
+// progression, so the event is skipped in that case.
+func (t *eventTracker) progressedEvent(ctx context.Context, params encoding.Params, state encoding.ChannelState, timeout channel.Timeout, idx channel.Index) *channel.ProgressedEvent {
+	decoded, err := t.cb.DecodeState(ctx, params, state)
+	if err != nil {
+		log.Println("Skipping progressed event, could not decode state: ", err)
+		return nil
+	}
+	return channel.NewProgressedEvent(t.cid, timeout, decoded, idx)
+}
+
+// lastActor returns the actor of the progression of the channel to the given version according to the on-chain
+// history. It falls back to participant A if the history is not available.
+func (t *eventTracker) lastActor(ctx context.Context, version uint64) channel.Index {
+	history, err := t.cb.GetChannelHistory(ctx, t.perunAddr, t.cid)
+	if err != nil {
+		log.Println("Error while getting actor of progression: ", err)
+		return 0
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Type == client.ChannelProgressed && history[i].Version() == version {
+			return partyIndex(history[i].PartyIdx)
+		}
+	}
+	return 0
+}
+
+// partyIndex converts the on-chain party index to a channel index.
+func partyIndex(partyIdx bool) channel.Index {
+	if partyIdx {
+		return 1
+	}
+	return 0
+}
+
+// eventTimestamp returns the block time of the event in seconds, or the fallback if the block time is not available.
+func eventTimestamp(ev client.ChannelEvent, fallback uint64) uint64 {
+	if ev.BlockTime.IsZero() || ev.BlockTime.Unix() <= 0 {
+		return fallback
+	}
+	return uint64(ev.BlockTime.Unix()) //nolint:gosec
 }
 
-func (p *PollingSubscription) Close() error {
-	return nil //TODO: implement Close in adjudicator subscription
+// remove records that the channel account was removed, which implies that the channel was concluded.
+func (t *eventTracker) remove() []channel.AdjudicatorEvent {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	return []channel.AdjudicatorEvent{channel.NewConcludedEvent(t.cid, &channel.ElapsedTimeout{}, t.version)}
 }
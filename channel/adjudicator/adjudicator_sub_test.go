@@ -0,0 +1,305 @@
+package adjudicator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/perun-network/perun-solana-backend/client"
+	"github.com/perun-network/perun-solana-backend/encoding"
+
+	pchannel "github.com/perun-network/perun-solana-backend/channel"
+	"perun.network/go-perun/channel"
+)
+
+// describe returns a short description of the event, such as "registered 1".
+func describe(ev channel.AdjudicatorEvent) string {
+	switch ev.(type) {
+	case *channel.RegisteredEvent:
+		return fmt.Sprintf("registered %d", ev.Version())
+	case *channel.ProgressedEvent:
+		return fmt.Sprintf("progressed %d", ev.Version())
+	case *channel.ConcludedEvent:
+		return fmt.Sprintf("concluded %d", ev.Version())
+	default:
+		return fmt.Sprintf("unknown %T", ev)
+	}
+}
+
+func describeAll(events []channel.AdjudicatorEvent) []string {
+	descriptions := make([]string, 0, len(events))
+	for _, ev := range events {
+		descriptions = append(descriptions, describe(ev))
+	}
+	return descriptions
+}
+
+func testChannel(control encoding.Control, version uint64) encoding.Channel {
+	return encoding.Channel{
+		Params:  encoding.Params{ChallengeDuration: 60, LedgerChannel: true},
+		State:   encoding.ChannelState{ChannelID: [32]byte{1}, Version: version},
+		Control: control,
+	}
+}
+
+func TestSubscriptionConfigWithDefaults(t *testing.T) {
+	def := DefaultSubscriptionConfig()
+	custom := SubscriptionConfig{
+		Source:            WebSocketSource,
+		WSURL:             "ws://localhost:1",
+		Commitment:        "finalized",
+		PollingInterval:   time.Second,
+		MaxReconnects:     1,
+		ReconnectInterval: time.Millisecond,
+		ReplayHistory:     true,
+	}
+
+	tests := []struct {
+		name string
+		cfg  SubscriptionConfig
+		want SubscriptionConfig
+	}{
+		{name: "zero value", cfg: SubscriptionConfig{}, want: def},
+		{name: "negative intervals", cfg: SubscriptionConfig{PollingInterval: -1, MaxReconnects: -1, ReconnectInterval: -1}, want: def},
+		{name: "custom", cfg: custom, want: custom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.withDefaults(); got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventTrackerUpdate(t *testing.T) {
+	funded := encoding.Control{FundedA: true, FundedB: true}
+	disputed := encoding.Control{FundedA: true, FundedB: true, Disputed: true, Timestamp: 100}
+	closed := encoding.Control{FundedA: true, FundedB: true, Closed: true}
+	forceClosed := encoding.Control{FundedA: true, FundedB: true, Disputed: true, Closed: true, Timestamp: 100}
+
+	tests := []struct {
+		name      string
+		snapshots []encoding.Channel
+		want      []string
+	}{
+		{name: "funded", snapshots: []encoding.Channel{testChannel(funded, 0)}},
+		{name: "first snapshot disputed", snapshots: []encoding.Channel{testChannel(disputed, 1)}, want: []string{"registered 1"}},
+		{
+			name:      "dispute with newer version",
+			snapshots: []encoding.Channel{testChannel(funded, 0), testChannel(disputed, 1), testChannel(disputed, 2)},
+			want:      []string{"registered 1", "registered 2"},
+		},
+		{
+			name:      "unchanged dispute",
+			snapshots: []encoding.Channel{testChannel(disputed, 1), testChannel(disputed, 1)},
+			want:      []string{"registered 1"},
+		},
+		{
+			name:      "force closed after dispute",
+			snapshots: []encoding.Channel{testChannel(disputed, 1), testChannel(forceClosed, 1), testChannel(forceClosed, 1)},
+			want:      []string{"registered 1", "concluded 1"},
+		},
+		{name: "first snapshot force closed", snapshots: []encoding.Channel{testChannel(forceClosed, 3)}, want: []string{"concluded 3"}},
+		{
+			name:      "closed cooperatively",
+			snapshots: []encoding.Channel{testChannel(funded, 0), testChannel(closed, 4)},
+			want:      []string{"concluded 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := eventTracker{cid: channel.ID{1}}
+			var events []channel.AdjudicatorEvent
+			for _, snapshot := range tt.snapshots {
+				events = append(events, tracker.update(context.Background(), snapshot)...)
+			}
+			if got := describeAll(events); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got events %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventTrackerReplay(t *testing.T) {
+	params := encoding.Params{ChallengeDuration: 60, LedgerChannel: true}
+	event := func(typ client.ChannelEventType, version uint64, blockTime int64) client.ChannelEvent {
+		ev := client.ChannelEvent{Type: typ, State: &encoding.ChannelState{Version: version}}
+		if blockTime != 0 {
+			ev.BlockTime = time.Unix(blockTime, 0)
+		}
+		return ev
+	}
+
+	tests := []struct {
+		name      string
+		history   []client.ChannelEvent
+		want      []string
+		deadlines []int64 // The deadlines of the registered events in seconds.
+	}{
+		{
+			name:    "opened and funded",
+			history: []client.ChannelEvent{event(client.ChannelOpened, 0, 10), {Type: client.ChannelFundedA}},
+		},
+		{
+			name:      "disputed with block time",
+			history:   []client.ChannelEvent{event(client.ChannelOpened, 0, 10), event(client.ChannelDisputed, 1, 20)},
+			want:      []string{"registered 1"},
+			deadlines: []int64{80},
+		},
+		{
+			name:      "disputed without block time",
+			history:   []client.ChannelEvent{event(client.ChannelDisputed, 1, 0)},
+			want:      []string{"registered 1"},
+			deadlines: []int64{160},
+		},
+		{
+			name: "force closed after dispute",
+			history: []client.ChannelEvent{
+				event(client.ChannelDisputed, 1, 20),
+				{Type: client.ChannelForceClosed},
+				event(client.ChannelDisputed, 2, 30),
+				event(client.ChannelClosed, 3, 40),
+			},
+			want:      []string{"registered 1", "concluded 1"},
+			deadlines: []int64{80},
+		},
+		{
+			name:    "closed cooperatively",
+			history: []client.ChannelEvent{event(client.ChannelOpened, 0, 10), event(client.ChannelClosed, 4, 20)},
+			want:    []string{"concluded 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := eventTracker{cid: channel.ID{1}}
+			events := tracker.replay(context.Background(), tt.history, params, 100)
+			if got := describeAll(events); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got events %v, want %v", got, tt.want)
+			}
+			var deadlines []int64
+			for _, ev := range events {
+				if _, ok := ev.(*channel.RegisteredEvent); ok {
+					deadlines = append(deadlines, ev.Timeout().(*Timeout).Deadline().Unix())
+				}
+			}
+			if fmt.Sprint(deadlines) != fmt.Sprint(tt.deadlines) {
+				t.Fatalf("got deadlines %v, want %v", deadlines, tt.deadlines)
+			}
+			if !tracker.seen && len(tt.history) > 0 {
+				t.Fatal("replayed history not recorded as seen")
+			}
+		})
+	}
+}
+
+func TestEventTrackerRemove(t *testing.T) {
+	tracker := eventTracker{cid: channel.ID{1}}
+	tracker.update(context.Background(), testChannel(encoding.Control{Disputed: true}, 1))
+	if got := describeAll(tracker.remove()); fmt.Sprint(got) != fmt.Sprint([]string{"concluded 1"}) {
+		t.Fatalf("got events %v, want concluded 1", got)
+	}
+	if got := tracker.remove(); len(got) != 0 {
+		t.Fatalf("got events %v after the channel was concluded", describeAll(got))
+	}
+}
+
+// accountServer is a JSON-RPC server that serves the given snapshots of the channel account, one per request and the
+// last one repeatedly. A nil snapshot is served as a missing account.
+type accountServer struct {
+	mtx       sync.Mutex
+	snapshots []*encoding.Channel
+	requests  int
+}
+
+func (s *accountServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getAccountInfo" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	s.mtx.Lock()
+	snapshot := s.snapshots[min(s.requests, len(s.snapshots)-1)]
+	s.requests++
+	s.mtx.Unlock()
+
+	value := "null"
+	if snapshot != nil {
+		buf := new(bytes.Buffer)
+		if err := bin.NewBorshEncoder(buf).Encode(snapshot); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		value = fmt.Sprintf(`{"data":["%s","base64"],"executable":false,"lamports":1,"owner":"%s","rentEpoch":0}`,
+			base64.StdEncoding.EncodeToString(buf.Bytes()), solana.SystemProgramID)
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%s}}`, req.ID, value)
+}
+
+func TestSubscription(t *testing.T) {
+	funded := testChannel(encoding.Control{FundedA: true, FundedB: true}, 0)
+	disputed := testChannel(encoding.Control{FundedA: true, FundedB: true, Disputed: true, Timestamp: 100}, 1)
+	progressed := testChannel(encoding.Control{FundedA: true, FundedB: true, Disputed: true, Timestamp: 100}, 2)
+	closed := testChannel(encoding.Control{FundedA: true, FundedB: true, Disputed: true, Closed: true, Timestamp: 100}, 2)
+	want := []string{"registered 1", "registered 2", "concluded 2"}
+
+	tests := []struct {
+		name string
+		cfg  SubscriptionConfig
+	}{
+		{name: "polling", cfg: SubscriptionConfig{Source: PollingSource, PollingInterval: time.Millisecond}},
+		{name: "websocket without endpoint", cfg: SubscriptionConfig{Source: WebSocketSource, PollingInterval: time.Millisecond}},
+		{name: "unreachable websocket", cfg: SubscriptionConfig{
+			Source:            WebSocketSource,
+			WSURL:             "ws://127.0.0.1:1",
+			PollingInterval:   time.Millisecond,
+			MaxReconnects:     2,
+			ReconnectInterval: time.Millisecond,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(&accountServer{snapshots: []*encoding.Channel{&funded, &disputed, &progressed, &closed, nil}})
+			defer server.Close()
+			cluster := client.LocalnetConfig()
+			cluster.RPCURL = server.URL
+			cb, err := client.NewContractBackendWithCluster(*client.NewRandomConfig(rand.New(rand.NewSource(1))), pchannel.BackendID, cluster) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sub channel.AdjudicatorSubscription
+			if tt.cfg.Source == PollingSource {
+				sub = NewAdjudicatorSubFromChannelID(context.Background(), cb, solana.PublicKey{2}, channel.ID{1}, tt.cfg)
+			} else {
+				sub = NewWSAdjudicatorSub(context.Background(), cb, solana.PublicKey{2}, channel.ID{1}, tt.cfg)
+			}
+			defer sub.Close() //nolint:errcheck
+
+			var got []string
+			for ev := sub.Next(); ev != nil; ev = sub.Next() {
+				got = append(got, describe(ev))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("got events %v, want %v", got, want)
+			}
+			if err := sub.Err(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package adjudicator
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/ws"
+	"github.com/perun-network/perun-solana-backend/client"
+
+	"perun.network/go-perun/channel"
+)
+
+const (
+	DefaultMaxReconnects     = 5
+	DefaultReconnectInterval = time.Duration(2) * time.Second
+)
+
+// errChannelRemoved signals that the channel account was removed and the subscription can stop.
+var errChannelRemoved = errors.New("channel account removed")
+
+// SubscriptionSource selects how adjudicator events are observed.
+type SubscriptionSource int
+
+const (
+	// PollingSource polls the channel account via RPC.
+	PollingSource SubscriptionSource = iota
+	// WebSocketSource subscribes to changes of the channel account via the websocket API.
+	WebSocketSource
+)
+
+// SubscriptionConfig configures the adjudicator event subscriptions. Zero fields are replaced by the defaults of
+// DefaultSubscriptionConfig when creating a subscription.
+type SubscriptionConfig struct {
+	Source            SubscriptionSource
+	WSURL             string             // The websocket endpoint, only used by WebSocketSource.
+	Commitment        rpc.CommitmentType // The commitment of account notifications, only used by WebSocketSource.
+	PollingInterval   time.Duration
+	MaxReconnects     int // Consecutive failed connections after which WebSocketSource falls back to polling.
+	ReconnectInterval time.Duration
+	ReplayHistory     bool // Whether to replay the on-chain history of the channel before observing changes.
+}
+
+// DefaultSubscriptionConfig returns the default configuration, which polls the channel account. It sets no websocket
+// endpoint, see SubscriptionConfigFromCluster.
+func DefaultSubscriptionConfig() SubscriptionConfig {
+	return SubscriptionConfig{
+		Source:            PollingSource,
+		Commitment:        rpc.CommitmentConfirmed,
+		PollingInterval:   DefaultSubscriptionPollingInterval,
+		MaxReconnects:     DefaultMaxReconnects,
+		ReconnectInterval: DefaultReconnectInterval,
+	}
+}
+
+// withDefaults returns the configuration with the zero fields set to the defaults of DefaultSubscriptionConfig.
+func (cfg SubscriptionConfig) withDefaults() SubscriptionConfig {
+	def := DefaultSubscriptionConfig()
+	if cfg.Commitment == "" {
+		cfg.Commitment = def.Commitment
+	}
+	if cfg.PollingInterval <= 0 {
+		cfg.PollingInterval = def.PollingInterval
+	}
+	if cfg.MaxReconnects <= 0 {
+		cfg.MaxReconnects = def.MaxReconnects
+	}
+	if cfg.ReconnectInterval <= 0 {
+		cfg.ReconnectInterval = def.ReconnectInterval
+	}
+	return cfg
+}
+
+// SubscriptionConfigFromCluster returns the default configuration with the websocket endpoint and the commitment of
+// the given cluster.
+func SubscriptionConfigFromCluster(cluster client.ClusterConfig) SubscriptionConfig {
+	cfg := DefaultSubscriptionConfig()
+	cfg.WSURL = cluster.WSURL
+	cfg.Commitment = cluster.Commitments.Subscribe
+	return cfg
+}
+
+var _ channel.AdjudicatorSubscription = (*WSSubscription)(nil)
+
+// WSSubscription is an AdjudicatorSubscription that subscribes to changes of the channel account via the websocket
+// API. It reconnects if the connection drops and falls back to polling if reconnecting keeps failing.
+type WSSubscription struct {
+	subscription
+	cfg SubscriptionConfig
+}
+
+// NewWSAdjudicatorSub creates a new WSSubscription for the channel with the given ID and starts listening. The
+// context is not used for the lifetime of the subscription, which ends with Close.
+func NewWSAdjudicatorSub(ctx context.Context, cb *client.ContractBackend, perunAddr solana.PublicKey, id channel.ID, cfg SubscriptionConfig) *WSSubscription {
+	cfg = cfg.withDefaults()
+	subCtx, cancel := context.WithCancel(context.Background())
+	sub := &WSSubscription{
+		subscription: newSubscription(cb, perunAddr, id, cfg, cancel),
+		cfg:          cfg,
+	}
+	go sub.run(subCtx)
+	return sub
+}
+
+func (w *WSSubscription) run(ctx context.Context) {
+	defer close(w.done)
+	if w.replayHistory {
+		w.replay(ctx)
+	}
+	if w.cfg.WSURL == "" {
+		log.Println("No websocket endpoint configured, falling back to polling")
+		w.pollLoop(ctx, w.cfg.PollingInterval)
+		return
+	}
+	failures := 0
+	for failures < w.cfg.MaxReconnects {
+		received, err := w.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, client.ErrCouldNotDecodeTx) {
+			w.setErr(err)
+			return
+		}
+		if errors.Is(err, errChannelRemoved) {
+			return
+		}
+		if received {
+			failures = 0
+		}
+		failures++
+		log.Println("Websocket subscription dropped, reconnecting: ", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.cfg.ReconnectInterval):
+		}
+	}
+	log.Println("Websocket subscription failed repeatedly, falling back to polling")
+	w.pollLoop(ctx, w.cfg.PollingInterval)
+}
+
+// listen connects to the websocket endpoint, subscribes to the channel account and emits events until the
+// connection drops. It reports whether at least one notification was received.
+func (w *WSSubscription) listen(ctx context.Context) (bool, error) {
+	channelPDA, err := client.ChannelPDA(w.cid, w.perunAddr)
+	if err != nil {
+		return false, err
+	}
+	wsClient, err := ws.Connect(ctx, w.cfg.WSURL)
+	if err != nil {
+		return false, err
+	}
+	defer wsClient.Close()
+
+	accSub, err := wsClient.AccountSubscribeWithOpts(channelPDA, w.cfg.Commitment, solana.EncodingBase64)
+	if err != nil {
+		return false, err
+	}
+	defer accSub.Unsubscribe()
+
+	// Catch up on changes that happened before subscribing or while disconnected.
+	stop, err := w.poll(ctx)
+	if err != nil {
+		return false, err
+	}
+	if stop {
+		return false, errChannelRemoved
+	}
+
+	received := false
+	for {
+		res, err := accSub.Recv(ctx)
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		if res.Value.Data == nil || len(res.Value.Data.GetBinary()) == 0 {
+			if w.handleRemoved(ctx) {
+				return received, errChannelRemoved
+			}
+			continue
+		}
+		chanInfo, err := client.DecodeChannel(res.Value.Data.GetBinary())
+		if err != nil {
+			return received, err
+		}
+		w.emit(ctx, w.tracker.update(ctx, chanInfo))
+	}
+}
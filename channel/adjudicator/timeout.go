@@ -0,0 +1,83 @@
+package adjudicator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/perun-network/perun-solana-backend/client"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+)
+
+// DefaultTimeoutPollingInterval is the maximal interval in which a Timeout polls the cluster time while waiting.
+const DefaultTimeoutPollingInterval = time.Duration(4) * time.Second
+
+var _ channel.Timeout = (*Timeout)(nil)
+
+// Timeout is a channel.Timeout that elapses once the cluster clock has passed the end of a dispute's challenge
+// duration. Using the cluster clock instead of the local time ensures that the timeout is never considered elapsed
+// before the on-chain program considers it elapsed.
+type Timeout struct {
+	cb           *client.ContractBackend
+	deadline     time.Time
+	pollInterval time.Duration
+}
+
+// NewTimeout creates a Timeout for a dispute registered at the given on-chain timestamp with the given challenge
+// duration in seconds.
+func NewTimeout(cb *client.ContractBackend, timestamp uint64, challengeDuration uint64) *Timeout {
+	return &Timeout{
+		cb:           cb,
+		deadline:     client.ChallengeDeadline(timestamp, challengeDuration),
+		pollInterval: DefaultTimeoutPollingInterval,
+	}
+}
+
+// Deadline returns the cluster time after which the timeout is elapsed.
+func (t *Timeout) Deadline() time.Time {
+	return t.deadline
+}
+
+// IsElapsed returns whether the cluster time has passed the deadline. If the cluster time cannot be fetched, the
+// timeout is considered not elapsed.
+func (t *Timeout) IsElapsed(ctx context.Context) bool {
+	remaining, err := t.Remaining(ctx)
+	if err != nil {
+		log.Println("Error while getting cluster time: ", err)
+		return false
+	}
+	return remaining <= 0
+}
+
+// Remaining returns the time until the cluster time passes the deadline.
+func (t *Timeout) Remaining(ctx context.Context) (time.Duration, error) {
+	return t.cb.RemainingUntil(ctx, t.deadline)
+}
+
+// Wait waits until the cluster time has passed the deadline or the context is done.
+func (t *Timeout) Wait(ctx context.Context) error {
+	for {
+		remaining, err := t.Remaining(ctx)
+		if err != nil {
+			log.Println("Error while getting cluster time: ", err)
+			remaining = t.pollInterval
+		}
+		if err == nil && remaining <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "ctx done")
+		case <-time.After(min(remaining, t.pollInterval)):
+		}
+	}
+}
+
+// String returns the deadline of the timeout.
+func (t *Timeout) String() string {
+	return fmt.Sprintf("<Cluster timeout: %v>", t.deadline)
+}
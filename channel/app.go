@@ -0,0 +1,76 @@
+package channel
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+)
+
+var _ pchannel.AppID = (*AppID)(nil)
+
+// AppID identifies a channel app by the program ID of the Solana program that defines its valid transitions.
+type AppID struct {
+	ProgramID solana.PublicKey
+}
+
+// NewAppID creates a new AppID for the app program with the given program ID.
+func NewAppID(programID solana.PublicKey) *AppID {
+	return &AppID{ProgramID: programID}
+}
+
+// MarshalBinary encodes the app ID into its binary representation.
+func (id AppID) MarshalBinary() ([]byte, error) {
+	return id.ProgramID.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the app ID from its binary representation.
+func (id *AppID) UnmarshalBinary(data []byte) error {
+	if len(data) != solana.PublicKeyLength {
+		return errors.Errorf("unexpected app ID length %d, want %d", len(data), solana.PublicKeyLength)
+	}
+	id.ProgramID = solana.PublicKeyFromBytes(data)
+	return nil
+}
+
+// Equal returns whether the given app ID identifies the same app program.
+func (id AppID) Equal(b pchannel.AppID) bool {
+	other, ok := b.(*AppID)
+	if !ok {
+		return false
+	}
+	return id.ProgramID.Equals(other.ProgramID)
+}
+
+// Key returns the key representation of the app ID.
+func (id AppID) Key() pchannel.AppIDKey {
+	return pchannel.AppIDKey(id.ProgramID.Bytes())
+}
+
+// String returns the program ID of the app.
+func (id AppID) String() string {
+	return id.ProgramID.String()
+}
+
+// ToAppID casts the definition of the given app to an AppID.
+func ToAppID(app pchannel.App) (*AppID, error) {
+	if app == nil || pchannel.IsNoApp(app) {
+		return nil, errors.New("expected app")
+	}
+	id, ok := app.Def().(*AppID)
+	if !ok {
+		return nil, errors.Errorf("unexpected app ID type %T", app.Def())
+	}
+	return id, nil
+}
+
+// appEthAddress derives the address representing the app in the Ethereum encoding of the channel parameters. It is
+// the last 20 bytes of the Keccak256 hash of the program ID, the same way Ethereum derives addresses from keys.
+func appEthAddress(app pchannel.App) (common.Address, error) {
+	id, err := ToAppID(app)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(crypto.Keccak256(id.ProgramID.Bytes())), nil
+}
@@ -70,6 +70,9 @@ func (sa *SolanaAsset) UnmarshalBinary(data []byte) error {
 		sa.Mint = nil // SOL does not have a mint address.
 		return nil
 	case SPLMagic:
+		if len(data) != 1+solana.PublicKeyLength {
+			return errors.Errorf("asset invalid: unexpected length %d", len(data))
+		}
 		sa.IsSOL = false
 		mint := solana.PublicKeyFromBytes(data[1:])
 		sa.Mint = &mint
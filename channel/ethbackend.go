@@ -142,7 +142,11 @@ func ToEthParams(params *channel.Params) (ChannelParams, error) {
 	}
 	var app common.Address
 	if params.App != nil && !channel.IsNoApp(params.App) {
-		return ChannelParams{}, errors.New("app is not supported in Solana backend")
+		var err error
+		app, err = appEthAddress(params.App)
+		if err != nil {
+			return ChannelParams{}, errors.WithMessage(err, "could not encode app")
+		}
 	}
 	return ChannelParams{
 		ChallengeDuration: new(big.Int).SetUint64(params.ChallengeDuration),
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/perun-network/perun-solana-backend/channel"
 	"github.com/perun-network/perun-solana-backend/client"
 	"github.com/perun-network/perun-solana-backend/encoding"
@@ -18,6 +19,7 @@ import (
 const (
 	MaxIterationsUntilAbort = 30
 	DefaultPollingInterval  = time.Duration(4) * time.Second
+	DefaultAbortTimeout     = time.Duration(2) * time.Minute
 )
 
 // Funder is a struct that implements the Funder interface for Stellar.
@@ -27,6 +29,7 @@ type Funder struct {
 	assetAddrs      []solana.PublicKey
 	maxIters        int
 	pollingInterval time.Duration
+	openAndFund     bool // Whether party A opens and funds the channel in a single transaction, see SetOpenAndFund.
 }
 
 // NewFunder creates a new Funder instance with the given parameters.
@@ -41,9 +44,34 @@ func NewFunder(
 		assetAddrs:      assetAddrs,
 		maxIters:        MaxIterationsUntilAbort,
 		pollingInterval: DefaultPollingInterval,
+		openAndFund:     true,
 	}
 }
 
+// SetOpenAndFund sets whether party A opens and funds the channel in a single transaction, which is enabled by
+// default. If the instructions do not fit into one transaction, the channel is opened and funded separately anyway.
+func (f *Funder) SetOpenAndFund(openAndFund bool) {
+	f.openAndFund = openAndFund
+}
+
+// SetWrapSOL sets whether native SOL is deposited as wrapped SOL through a temporary wrapped SOL account. The setting
+// applies to the contract backend of the funder and thereby also to withdrawals, see client.ContractBackend.SetWrapSOL.
+func (f *Funder) SetWrapSOL(wrap bool) {
+	f.cb.SetWrapSOL(wrap)
+}
+
+// NewFunderFromCluster creates a new Funder for the Perun program and with the polling interval of the cluster config
+// of the given contract backend.
+func NewFunderFromCluster(cb *client.ContractBackend, assetAddrs []solana.PublicKey) (*Funder, error) {
+	cluster := cb.ClusterConfig()
+	if cluster.PerunProgramID.IsZero() {
+		return nil, errors.New("cluster config does not set the Perun program ID")
+	}
+	f := NewFunder(cb, cluster.PerunProgramID, assetAddrs)
+	f.pollingInterval = time.Duration(cluster.Timeouts.PollingInterval)
+	return f, nil
+}
+
 // GetPerunAddr returns the perun address of the funder.
 func (f *Funder) GetPerunAddr() solana.PublicKey {
 	return f.perunAddr
@@ -63,7 +91,12 @@ func (f *Funder) Fund(ctx context.Context, req pchannel.FundingReq) error {
 	}
 
 	if req.Idx == pchannel.Index(0) {
-		err := f.openChannel(ctx, req)
+		var err error
+		if f.openAndFund && needFunding(req.State.Balances[0], req.State.Assets) {
+			err = f.openAndFundChannel(ctx, req)
+		} else {
+			err = f.openChannel(ctx, req)
+		}
 		if err != nil {
 			return err
 		}
@@ -104,8 +137,11 @@ func (f *Funder) fundParty(ctx context.Context, req pchannel.FundingReq) error {
 		select {
 		case <-ctx.Done():
 			timeoutErr := makeTimeoutErr([]pchannel.Index{req.Idx}, 0)
-			errAbort := f.AbortChannel(ctx, req.State)
 			log.Printf("%s: Aborting channel due to timeout...", party)
+			// The funding context is done, so the abort gets its own deadline.
+			abortCtx, cancel := context.WithTimeout(context.Background(), DefaultAbortTimeout)
+			errAbort := f.AbortChannel(abortCtx, req.State)
+			cancel()
 			if errAbort != nil {
 				return errAbort
 			}
@@ -209,17 +245,63 @@ func (f *Funder) fundParty(ctx context.Context, req pchannel.FundingReq) error {
 			}
 		}
 	}
-	return f.AbortChannel(ctx, req.State)
+	if err := f.AbortChannel(ctx, req.State); err != nil {
+		return err
+	}
+	return makeTimeoutErr([]pchannel.Index{req.Idx}, 0)
 }
 
-// AbortChannel aborts the channel with the given state.
+// AbortChannel aborts the funding of the channel with the given state and waits until the deposits made so far are
+// refunded, which is the case once the channel account is removed or no party is marked as funded anymore.
 func (f *Funder) AbortChannel(ctx context.Context, state *pchannel.State) error {
 	log.Println("Aborting channel...")
-	return f.cb.Abort(ctx)
+	if err := f.cb.Abort(ctx, f.perunAddr, state.ID); err != nil {
+		return errors.Join(errors.New("error while aborting channel"), err)
+	}
+
+	for i := 0; i < f.maxIters; i++ {
+		chanInfo, err := f.cb.GetChannelInfo(ctx, f.perunAddr, state.ID)
+		switch {
+		case errors.Is(err, rpc.ErrNotFound):
+			log.Println("Channel aborted and account removed")
+			return nil
+		case err != nil:
+			log.Println("Error while polling for aborted channel: ", err)
+		case !chanInfo.Control.FundedA && !chanInfo.Control.FundedB:
+			log.Println("Channel aborted and deposits refunded")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(f.pollingInterval):
+		}
+	}
+	return errors.New("deposits not refunded after max iterations")
 }
 
 // FundChannel funds the channel with the given state.
 func (f *Funder) FundChannel(ctx context.Context, state *pchannel.State, funderIdx bool) error {
+	if err := f.checkAssets(ctx, state); err != nil {
+		return err
+	}
+
+	err := f.cb.Fund(ctx, f.perunAddr, state.ID, funderIdx)
+	if err == nil {
+		return nil
+	}
+	// The party might have funded the channel before, which is only trusted if the on-chain control reflects it.
+	chanInfo, errInfo := f.cb.GetChannelInfo(ctx, f.perunAddr, state.ID)
+	if errInfo == nil && (funderIdx && chanInfo.Control.FundedB || !funderIdx && chanInfo.Control.FundedA) {
+		log.Println("Party already funded the channel")
+		return nil
+	}
+	return err
+}
+
+// checkAssets checks that the assets of the given state are the assets of the funder and that their mints can be used.
+func (f *Funder) checkAssets(ctx context.Context, state *pchannel.State) error {
 	balsSolana, err := encoding.MakeBalances(state.Allocation)
 	if err != nil {
 		return errors.New("error while making balances")
@@ -228,19 +310,62 @@ func (f *Funder) FundChannel(ctx context.Context, state *pchannel.State, funderI
 	if !containsAllAssets(balsSolana.Tokens, f.assetAddrs) {
 		return errors.New("asset address is not equal to the address stored in the state")
 	}
+	return f.checkMints(ctx, state.Assets)
+}
 
-	return f.cb.Fund(ctx, f.perunAddr, state.ID, funderIdx)
+// checkMints checks that the mints of the token assets are owned by the token program or the Token-2022 program and do
+// not use extensions the channel cannot support, see client.ContractBackend.GetMintInfo.
+func (f *Funder) checkMints(ctx context.Context, assets []pchannel.Asset) error {
+	for _, asset := range assets {
+		crossAsset, ok := asset.(*channel.SolanaCrossAsset)
+		if !ok || crossAsset.Asset.IsSOL {
+			continue
+		}
+		mint, err := channel.MakeAssetAddress(crossAsset.Asset)
+		if err != nil {
+			return err
+		}
+		if _, err := f.cb.GetMintInfo(ctx, mint); err != nil {
+			return errors.Join(errors.New("error while checking mint"), err)
+		}
+	}
+	return nil
 }
 
 func (f *Funder) openChannel(ctx context.Context, req pchannel.FundingReq) error {
 	err := f.cb.Open(ctx, f.perunAddr, req.Params, req.State)
 	if err != nil {
+		// The channel might have been opened before, which is only trusted if its account exists.
+		if _, errInfo := f.cb.GetChannelInfo(ctx, f.perunAddr, req.State.ID); errInfo == nil {
+			log.Println("Channel already opened")
+			return nil
+		}
 		return errors.Join(errors.New("error while opening channel in party A"), err)
 	}
 
 	return nil
 }
 
+// openAndFundChannel opens the channel and funds the balance of party A, in a single transaction if the instructions
+// fit into one.
+func (f *Funder) openAndFundChannel(ctx context.Context, req pchannel.FundingReq) error {
+	if err := f.checkAssets(ctx, req.State); err != nil {
+		return err
+	}
+	combined, err := f.cb.OpenAndFund(ctx, f.perunAddr, req.Params, req.State)
+	if err != nil {
+		// If the channel was opened before or only funding failed after opening separately, party A funds it when
+		// polling.
+		if _, errInfo := f.cb.GetChannelInfo(ctx, f.perunAddr, req.State.ID); errInfo == nil {
+			log.Println("Channel opened but not funded: ", err)
+			return nil
+		}
+		return errors.Join(errors.New("error while opening and funding channel in party A"), err)
+	}
+	log.Println("Channel opened and funded by party A, single transaction: ", combined)
+	return nil
+}
+
 func getPartyByIndex(funderIdx pchannel.Index) string {
 	if funderIdx == 1 {
 		return "Party B"
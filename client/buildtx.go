@@ -10,6 +10,9 @@ import (
 	"github.com/gagliardetto/solana-go/rpc/ws"
 )
 
+// ErrNoRPCEndpoint is returned if the backend is used without an RPC endpoint, see NewContractBackendWithCluster.
+var ErrNoRPCEndpoint = errors.New("no RPC endpoint configured")
+
 type Sender interface {
 	SendTx(context.Context, *solana.Transaction) (solana.Signature, error)
 	SendAndConfirmTx(context.Context, *solana.Transaction, *ws.Client) (solana.Signature, error)
@@ -35,14 +38,15 @@ func (s *TxSender) SetRPCClient(rpcClient *rpc.Client) error {
 	return nil
 }
 
+// GetRPCClient returns the RPC client, which is nil if no RPC endpoint is configured.
 func (s *TxSender) GetRPCClient() *rpc.Client {
-	if s.rpcClient == nil {
-		s.rpcClient = rpc.New(rpc.LocalNet_RPC) // Default to LocalNet_RPC if no client is set.
-	}
 	return s.rpcClient
 }
 
 func (s *TxSender) SendTx(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	if s.rpcClient == nil {
+		return solana.Signature{}, ErrNoRPCEndpoint
+	}
 	sig, err := s.rpcClient.SendTransaction(
 		ctx,
 		tx,
@@ -51,6 +55,9 @@ func (s *TxSender) SendTx(ctx context.Context, tx *solana.Transaction) (solana.S
 }
 
 func (s *TxSender) SendAndConfirmTx(ctx context.Context, tx *solana.Transaction, wsClient *ws.Client) (solana.Signature, error) {
+	if s.rpcClient == nil {
+		return solana.Signature{}, ErrNoRPCEndpoint
+	}
 	sig, err := confirm.SendAndConfirmTransaction(
 		ctx,
 		s.rpcClient,
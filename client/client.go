@@ -2,28 +2,45 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"slices"
+	"time"
 
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/perun-network/perun-solana-backend/channel"
 	"github.com/perun-network/perun-solana-backend/encoding"
 	"github.com/pkg/errors"
 	pchannel "perun.network/go-perun/channel"
+	pwallet "perun.network/go-perun/wallet"
 )
 
 // ErrCouldNotDecodeTx is returned when the tx could not be decoded.
 var ErrCouldNotDecodeTx = errors.New("could not decode tx output")
 
+// ChallengeNotExpiredError is returned by ForceClose if the challenge duration of the dispute has not passed yet.
+type ChallengeNotExpiredError struct {
+	Remaining time.Duration // The time until the challenge duration has passed according to the cluster clock.
+}
+
+func (e *ChallengeNotExpiredError) Error() string {
+	return fmt.Sprintf("challenge duration not expired, %v remaining", e.Remaining)
+}
+
 // SolanaClient provides functions to interact with the Solana blockchain.
-// It includes methods for opening, aborting, funding, disputing, closing, and force closing channels.
+// It includes methods for opening, aborting, funding, disputing, closing, force closing, progressing and withdrawing
+// from channels.
 type SolanaClient interface {
 	Open(ctx context.Context, perunAddr solana.PublicKey, params *pchannel.Params, state *pchannel.State) error
-	Abort(ctx context.Context) error
+	Abort(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID) error
 	Fund(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, funderIdx bool) error
-	Dispute(ctx context.Context) error
-	Close(ctx context.Context) error
-	ForceClose(ctx context.Context) error
+	Dispute(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig, subChannels []pchannel.SignedState) error
+	Close(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig) error
+	ForceClose(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, subChannels []pchannel.ID) error
+	Progress(ctx context.Context, perunAddr solana.PublicKey, appProgram solana.PublicKey, state *pchannel.State, actorIdx bool, sig pwallet.Sig) error
+	Withdraw(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, partyIdx bool, oneWithdrawer bool) error
 	GetChannelInfo(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID) (encoding.Channel, error)
 }
 
@@ -31,74 +48,428 @@ var _ SolanaClient = (*ContractBackend)(nil)
 
 func (cb *ContractBackend) Open(ctx context.Context, perunAddr solana.PublicKey, params *pchannel.Params, state *pchannel.State) error {
 	log.Println("Open called by contract backend")
-	rpcClient := cb.signer.sender.GetRPCClient()
-	recent, err := rpcClient.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	openIx, err := cb.NewOpenInstruction(perunAddr, params, state)
+	if err != nil {
+		return errors.Wrap(err, "Open: could not create open instruction")
+	}
+	if err := cb.invokeInstructions(ctx, OperationOpen, openIx); err != nil {
+		return errors.Wrap(err, "Open")
+	}
+	return nil
+}
+
+func (cb *ContractBackend) Abort(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID) error {
+	log.Println("Abort called by contract backend")
+	chanInfo, err := cb.GetChannelInfo(ctx, perunAddr, chanID)
+	if err != nil {
+		return errors.Wrap(err, "Abort: could not get channel info")
+	}
+	control := chanInfo.Control
+	if control.FundedA && control.FundedB {
+		return errors.New("Abort: channel is already funded by both parties")
+	}
+	var receivers []solana.PublicKey
+	if control.FundedA {
+		receivers = append(receivers, chanInfo.Params.A.SolanaAddress)
+	}
+	if control.FundedB {
+		receivers = append(receivers, chanInfo.Params.B.SolanaAddress)
+	}
+
+	abortIx, err := cb.NewAbortFundingInstruction(perunAddr, chanID, receivers)
 	if err != nil {
-		return errors.Wrap(err, "Open: could not get latest blockhash")
+		return errors.Wrap(err, "Abort: could not create abort funding instruction")
+	}
+	if err := cb.invokeInstructions(ctx, OperationAbort, abortIx); err != nil {
+		return errors.Wrap(err, "Abort")
 	}
+	return nil
+}
+
+// Fund deposits the balance of the given party into the channel. The vault token accounts of the SPL tokens of the
+// channel are created first if they do not exist yet.
+func (cb *ContractBackend) Fund(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, funderIdx bool) error {
+	log.Println("Fund called by contract backend")
+	chanInfo, err := cb.GetChannelInfo(ctx, perunAddr, chanID)
+	if err != nil {
+		return errors.Wrap(err, "Fund: could not get channel info")
+	}
+	instructions, err := cb.fundInstructions(ctx, perunAddr, chanID, chanInfo.State.Balances, funderIdx)
+	if err != nil {
+		return errors.Wrap(err, "Fund")
+	}
+	if err := cb.invokeInstructions(ctx, OperationFund, instructions...); err != nil {
+		return errors.Wrap(err, "Fund")
+	}
+	return nil
+}
 
+// OpenAndFund opens the channel and deposits the balance of party A in a single transaction, so that the channel
+// never exists unfunded. If the instructions do not fit into one transaction, the channel is opened and funded in two
+// transactions instead. It reports whether a single transaction was used.
+func (cb *ContractBackend) OpenAndFund(ctx context.Context, perunAddr solana.PublicKey, params *pchannel.Params, state *pchannel.State) (bool, error) {
+	log.Println("OpenAndFund called by contract backend")
 	openIx, err := cb.NewOpenInstruction(perunAddr, params, state)
 	if err != nil {
-		return errors.Wrap(err, "Open: could not create open instruction")
+		return false, errors.Wrap(err, "OpenAndFund: could not create open instruction")
+	}
+	balances, err := encoding.MakeBalances(state.Allocation)
+	if err != nil {
+		return false, errors.Wrap(err, "OpenAndFund: could not encode balances")
+	}
+	fundIxs, err := cb.fundInstructions(ctx, perunAddr, state.ID, balances, false)
+	if err != nil {
+		return false, errors.Wrap(err, "OpenAndFund")
 	}
 
-	openTx, err := solana.NewTransaction(
-		[]solana.Instruction{openIx},
-		recent.Value.Blockhash,
-		solana.TransactionPayer(cb.signer.privateKey.PublicKey()),
-	)
+	err = cb.invokeInstructions(ctx, OperationOpenAndFund, append([]solana.Instruction{openIx}, fundIxs...)...)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, ErrTxTooLarge) {
+		return false, errors.Wrap(err, "OpenAndFund")
+	}
+
+	log.Println("Open and fund instructions do not fit into one transaction, opening and funding separately")
+	if err := cb.invokeInstructions(ctx, OperationOpen, openIx); err != nil {
+		return false, errors.Wrap(err, "OpenAndFund")
+	}
+	if err := cb.invokeInstructions(ctx, OperationFund, fundIxs...); err != nil {
+		return false, errors.Wrap(err, "OpenAndFund")
+	}
+	return false, nil
+}
+
+// fundInstructions returns the instructions depositing the balance of the given party of the channel with the given
+// on-chain balances. These create the vault token accounts and fund the channel, wrapping the deposit of native SOL
+// through the temporary wrapped SOL account if SOL is wrapped.
+func (cb *ContractBackend) fundInstructions(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, balances encoding.Balances, funderIdx bool) ([]solana.Instruction, error) {
+	channelPDA, err := ChannelPDA(chanID, perunAddr)
 	if err != nil {
-		return errors.Wrap(err, "Open: could not create transaction")
+		return nil, errors.Wrap(err, "could not get channel PDA")
 	}
-	_, err = cb.InvokeAndConfirmSignedTx(ctx, openTx)
+	mints, err := cb.splMints(ctx, balances.Tokens)
 	if err != nil {
-		return errors.Wrap(err, "Open: could not invoke signed transaction")
+		return nil, err
+	}
+	amounts, err := cb.fundingAmounts(ctx, balances, funderIdx, mints)
+	if err != nil {
+		return nil, err
+	}
+	instructions := make([]solana.Instruction, 0, len(mints)+1)
+	for _, mint := range mints {
+		createVaultIx, err := cb.NewCreateVaultInstruction(channelPDA, mint)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create vault instruction")
+		}
+		instructions = append(instructions, createVaultIx)
+	}
+	wrapSOL := cb.holdsWrappedSOL(balances.Tokens)
+	if wrapSOL {
+		wrapIxs, err := cb.NewWrapSOLInstructions(ctx, chanID, cb.wrappedSOLAmount(balances.Tokens, amounts))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create wrap instructions")
+		}
+		instructions = append(instructions, wrapIxs...)
+	}
+
+	// Without transfer fees the program transfers the balance itself, so the amounts are only passed along with fees.
+	fundAmounts := amounts
+	if !hasTransferFee(mints) {
+		fundAmounts = nil
+	}
+	fundIx, err := cb.NewFundInstruction(perunAddr, chanID, funderIdx, fundAmounts, mints)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create fund instruction")
+	}
+	instructions = append(instructions, fundIx)
+	if wrapSOL {
+		// Return the rent of the temporary wrapped SOL account.
+		unwrapIx, err := cb.NewUnwrapSOLInstruction(chanID)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create unwrap instruction")
+		}
+		instructions = append(instructions, unwrapIx)
+	}
+	return instructions, nil
+}
+
+// Dispute registers the given state together with the signatures of both participants on-chain. The given
+// sub-channels are registered in the same instruction.
+func (cb *ContractBackend) Dispute(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig, subChannels []pchannel.SignedState) error {
+	log.Println("Dispute called by contract backend")
+	disputeIx, err := cb.NewDisputeInstruction(perunAddr, state, sigs, subChannels)
+	if err != nil {
+		return errors.Wrap(err, "Dispute: could not create dispute instruction")
+	}
+	if err := cb.invokeInstructions(ctx, OperationDispute, disputeIx); err != nil {
+		return errors.Wrap(err, "Dispute")
 	}
 	return nil
 }
 
-func (cb *ContractBackend) Abort(ctx context.Context) error {
-	return nil //TODO
+// Close concludes the channel cooperatively with the given final state and the signatures of both participants. The
+// state and signatures are checked against the on-chain participants before the transaction is sent.
+func (cb *ContractBackend) Close(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig) error {
+	log.Println("Close called by contract backend")
+	if err := cb.checkCloseState(ctx, perunAddr, state, sigs); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+	closeIx, err := cb.NewCloseInstruction(perunAddr, state, sigs)
+	if err != nil {
+		return errors.Wrap(err, "Close: could not create close instruction")
+	}
+	if err := cb.invokeInstructions(ctx, OperationClose, closeIx); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+	return nil
 }
 
-func (cb *ContractBackend) Fund(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, funderIdx bool) error {
-	log.Println("Fund called by contract backend")
-	rpcClient := cb.signer.sender.GetRPCClient()
+// checkCloseState checks that the given state is final and signed by both participants of the on-chain channel.
+func (cb *ContractBackend) checkCloseState(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig) error {
+	if !state.IsFinal {
+		return errors.New("state is not final")
+	}
+	if len(sigs) != 2 { //nolint:gomnd
+		return errors.New("expected exactly two signatures")
+	}
+	chanInfo, err := cb.GetChannelInfo(ctx, perunAddr, state.ID)
+	if err != nil {
+		return errors.Wrap(err, "could not get channel info")
+	}
+	parts := []encoding.Participant{chanInfo.Params.A, chanInfo.Params.B}
+	for i, part := range parts {
+		addr, err := part.ToWalletParticipant()
+		if err != nil {
+			return errors.Wrapf(err, "could not decode participant %d", i)
+		}
+		ok, err := channel.Backend.Verify(addr, state, sigs[i])
+		if err != nil {
+			return errors.Wrapf(err, "could not verify signature of participant %d", i)
+		}
+		if !ok {
+			return errors.Errorf("invalid signature of participant %d", i)
+		}
+	}
+	return nil
+}
 
-	recent, err := rpcClient.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+// ForceClose concludes a disputed channel after its challenge duration has passed. The funds locked in the given
+// sub-channels and virtual channels are settled into the channel according to their registered states. If the
+// challenge duration has not passed yet according to the cluster clock, a ChallengeNotExpiredError is returned
+// without sending a transaction.
+func (cb *ContractBackend) ForceClose(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, subChannels []pchannel.ID) error {
+	log.Println("ForceClose called by contract backend")
+	chanInfo, err := cb.GetChannelInfo(ctx, perunAddr, chanID)
+	if err != nil {
+		return errors.Wrap(err, "ForceClose: could not get channel info")
+	}
+	if !chanInfo.Control.Disputed {
+		return errors.New("ForceClose: channel is not disputed")
+	}
+	deadline := ChallengeDeadline(chanInfo.Control.Timestamp, chanInfo.Params.ChallengeDuration)
+	remaining, err := cb.RemainingUntil(ctx, deadline)
 	if err != nil {
-		return errors.Wrap(err, "Fund: could not get latest blockhash")
+		return errors.Wrap(err, "ForceClose: could not get cluster time")
+	}
+	if remaining > 0 {
+		return &ChallengeNotExpiredError{Remaining: remaining}
 	}
 
-	fundIx, err := cb.NewFundInstruction(perunAddr, chanID, funderIdx)
+	forceCloseIx, err := cb.NewForceCloseInstruction(perunAddr, chanID, subChannels)
 	if err != nil {
-		return errors.Wrap(err, "Fund: could not create fund instruction")
+		return errors.Wrap(err, "ForceClose: could not create force close instruction")
 	}
-	fundTx, err := solana.NewTransaction(
-		[]solana.Instruction{fundIx},
-		recent.Value.Blockhash,
-		solana.TransactionPayer(cb.signer.privateKey.PublicKey()),
-	)
+	if err := cb.invokeInstructions(ctx, OperationForceClose, forceCloseIx); err != nil {
+		return errors.Wrap(err, "ForceClose")
+	}
+	return nil
+}
+
+// Progress progresses a disputed app channel to the given state, signed by the participant with the given index. The
+// transition is validated on-chain by the given app program.
+func (cb *ContractBackend) Progress(ctx context.Context, perunAddr solana.PublicKey, appProgram solana.PublicKey, state *pchannel.State, actorIdx bool, sig pwallet.Sig) error {
+	log.Println("Progress called by contract backend")
+	progressIx, err := cb.NewProgressInstruction(perunAddr, appProgram, state, actorIdx, sig)
 	if err != nil {
-		return errors.Wrap(err, "Fund: could not create transaction")
+		return errors.Wrap(err, "Progress: could not create progress instruction")
+	}
+	if err := cb.invokeInstructions(ctx, OperationProgress, progressIx); err != nil {
+		return errors.Wrap(err, "Progress")
 	}
-	_, err = cb.InvokeAndConfirmSignedTx(ctx, fundTx)
+	return nil
+}
+
+// Withdraw pays out the balance of the given party of a closed channel, including its SPL token balances. If
+// oneWithdrawer is set, both parties are paid out in the same transaction. If SOL is wrapped, the participant's payout
+// of wrapped SOL is unwrapped, the other party receives wrapped SOL.
+func (cb *ContractBackend) Withdraw(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, partyIdx bool, oneWithdrawer bool) error {
+	log.Println("Withdraw called by contract backend")
+	instructions, err := cb.withdrawInstructions(ctx, perunAddr, chanID, partyIdx, oneWithdrawer)
 	if err != nil {
-		return errors.Wrap(err, "Fund: could not invoke signed transaction")
+		return errors.Wrap(err, "Withdraw")
+	}
+	if err := cb.invokeInstructions(ctx, OperationWithdraw, instructions...); err != nil {
+		return errors.Wrap(err, "Withdraw")
 	}
 	return nil
 }
 
-func (cb *ContractBackend) Dispute(ctx context.Context) error {
-	return nil //TODO
+// withdrawInstructions returns the instructions paying out the balance of the given party of the closed channel, see
+// Withdraw.
+func (cb *ContractBackend) withdrawInstructions(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, partyIdx bool, oneWithdrawer bool) ([]solana.Instruction, error) {
+	chanInfo, err := cb.GetChannelInfo(ctx, perunAddr, chanID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel info")
+	}
+	var receivers []solana.PublicKey
+	switch {
+	case oneWithdrawer:
+		receivers = []solana.PublicKey{chanInfo.Params.A.SolanaAddress, chanInfo.Params.B.SolanaAddress}
+	case partyIdx:
+		receivers = []solana.PublicKey{chanInfo.Params.B.SolanaAddress}
+	default:
+		receivers = []solana.PublicKey{chanInfo.Params.A.SolanaAddress}
+	}
+
+	mints, err := cb.splMints(ctx, chanInfo.State.Balances.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	withdrawIx, err := cb.NewWithdrawInstruction(perunAddr, chanID, partyIdx, oneWithdrawer, receivers, mints)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create withdraw instruction")
+	}
+	participant := cb.signer.participant.SolanaAddress
+	wrapSOL := cb.holdsWrappedSOL(chanInfo.State.Balances.Tokens)
+	var instructions []solana.Instruction
+	for _, receiver := range receivers {
+		if !wrapSOL {
+			break
+		}
+		// The participant is paid out into its temporary wrapped SOL account, the other party into its associated
+		// token account.
+		if receiver.Equals(participant) {
+			wrapIxs, err := cb.NewWrapSOLInstructions(ctx, chanID, 0)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not create wrap instructions")
+			}
+			instructions = append(instructions, wrapIxs...)
+			continue
+		}
+		createIx, err := cb.NewCreateTokenAccountInstruction(receiver, wrappedSOLMint)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create wrapped SOL account instruction")
+		}
+		instructions = append(instructions, createIx)
+	}
+	instructions = append(instructions, withdrawIx)
+	if wrapSOL && solana.PublicKeySlice(receivers).Has(participant) {
+		unwrapIx, err := cb.NewUnwrapSOLInstruction(chanID)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create unwrap instruction")
+		}
+		instructions = append(instructions, unwrapIx)
+	}
+	return instructions, nil
+}
+
+// splMints returns the mints of the SPL tokens among the given channel tokens. Native SOL is only included as wrapped
+// SOL if SOL is wrapped, assets of other chains are skipped.
+func (cb *ContractBackend) splMints(ctx context.Context, tokens []encoding.CrossAsset) ([]MintInfo, error) {
+	var mints []MintInfo
+	for _, t := range tokens {
+		if !cb.isSPLToken(t) {
+			continue
+		}
+		if t.SolanaAddress.IsZero() || t.SolanaAddress.Equals(wrappedSOLMint.Mint) {
+			mints = append(mints, wrappedSOLMint)
+			continue
+		}
+		mint, err := cb.GetMintInfo(ctx, t.SolanaAddress)
+		if err != nil {
+			return nil, err
+		}
+		mints = append(mints, mint)
+	}
+	return mints, nil
+}
+
+// isSPLToken returns whether the given channel token is held as an SPL token on this chain, which includes native
+// SOL if SOL is wrapped.
+func (cb *ContractBackend) isSPLToken(t encoding.CrossAsset) bool {
+	return t.Chain == encoding.Chain(cb.chainID) && (cb.WrapSOL() || !t.SolanaAddress.IsZero()) //nolint:gosec
+}
+
+// isWrappedSOL returns whether the given channel token is moved through the temporary wrapped SOL account of the
+// participant, which is the case for native SOL and wrapped SOL of this chain if SOL is wrapped.
+func (cb *ContractBackend) isWrappedSOL(t encoding.CrossAsset) bool {
+	return cb.WrapSOL() && t.Chain == encoding.Chain(cb.chainID) && //nolint:gosec
+		(t.SolanaAddress.IsZero() || t.SolanaAddress.Equals(wrappedSOLMint.Mint))
+}
+
+// holdsWrappedSOL returns whether any of the given channel tokens is moved through the temporary wrapped SOL account.
+func (cb *ContractBackend) holdsWrappedSOL(tokens []encoding.CrossAsset) bool {
+	return slices.ContainsFunc(tokens, cb.isWrappedSOL)
+}
+
+// wrappedSOLAmount returns the sum of the given amounts of the channel tokens moved through the temporary wrapped SOL
+// account.
+func (cb *ContractBackend) wrappedSOLAmount(tokens []encoding.CrossAsset, amounts []uint64) uint64 {
+	total := uint64(0)
+	for i, t := range tokens {
+		if cb.isWrappedSOL(t) && i < len(amounts) {
+			total += amounts[i]
+		}
+	}
+	return total
 }
 
-func (cb *ContractBackend) Close(ctx context.Context) error {
-	return nil //TODO
+// hasTransferFee returns whether any of the given mints charges a transfer fee.
+func hasTransferFee(mints []MintInfo) bool {
+	for _, mint := range mints {
+		if mint.TransferFee != nil {
+			return true
+		}
+	}
+	return false
 }
 
-func (cb *ContractBackend) ForceClose(ctx context.Context) error {
-	return nil //TODO
+// fundingAmounts returns the amount the given party has to transfer per channel token, so that the vault receives the
+// party's balance after transfer fees. The given mints are the SPL mints of the channel tokens in order.
+func (cb *ContractBackend) fundingAmounts(ctx context.Context, balances encoding.Balances, funderIdx bool, mints []MintInfo) ([]uint64, error) {
+	bals := balances.BalA
+	if funderIdx {
+		bals = balances.BalB
+	}
+	amounts := make([]uint64, len(bals))
+	copy(amounts, bals)
+
+	var epoch *uint64
+	mintIdx := 0
+	for i, t := range balances.Tokens {
+		if !cb.isSPLToken(t) {
+			continue
+		}
+		mint := mints[mintIdx]
+		mintIdx++
+		if mint.TransferFee == nil || i >= len(amounts) {
+			continue
+		}
+		if epoch == nil {
+			clock, err := cb.GetClock(ctx)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not get epoch for transfer fee")
+			}
+			epoch = &clock.Epoch
+		}
+		gross, err := mint.TransferFee.GrossAmount(amounts[i], *epoch)
+		if err != nil {
+			return nil, err
+		}
+		amounts[i] = gross
+	}
+	return amounts, nil
 }
 
 func (cb *ContractBackend) GetChannelInfo(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID) (encoding.Channel, error) {
@@ -106,12 +477,15 @@ func (cb *ContractBackend) GetChannelInfo(ctx context.Context, perunAddr solana.
 	if err != nil {
 		return encoding.Channel{}, errors.Wrap(err, "GetChannelInfo: could not get channel PDA")
 	}
-	rpcClient := cb.signer.sender.GetRPCClient()
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return encoding.Channel{}, errors.Wrap(err, "GetChannelInfo")
+	}
 	accountInfo, err := rpcClient.GetAccountInfoWithOpts(
 		ctx,
 		channelPDA,
 		&rpc.GetAccountInfoOpts{
-			Commitment: rpc.CommitmentFinalized,
+			Commitment: cb.cluster.Commitments.Read,
 		},
 	)
 	if err != nil {
@@ -120,10 +494,19 @@ func (cb *ContractBackend) GetChannelInfo(ctx context.Context, perunAddr solana.
 	if accountInfo == nil {
 		return encoding.Channel{}, errors.New("GetChannelInfo: account info is nil")
 	}
-	borshDec := bin.NewBorshDecoder(accountInfo.Value.Data.GetBinary())
+	channel, err := DecodeChannel(accountInfo.Value.Data.GetBinary())
+	if err != nil {
+		return encoding.Channel{}, errors.Wrap(err, "GetChannelInfo")
+	}
+	return channel, nil
+}
+
+// DecodeChannel decodes the data of a channel account.
+func DecodeChannel(data []byte) (encoding.Channel, error) {
+	borshDec := bin.NewBorshDecoder(data)
 	var channel encoding.Channel
 	if err := borshDec.Decode(&channel); err != nil {
-		return encoding.Channel{}, errors.Wrap(ErrCouldNotDecodeTx, "GetChannelInfo: could not decode channel data")
+		return encoding.Channel{}, errors.Wrap(ErrCouldNotDecodeTx, "could not decode channel data")
 	}
 	return channel, nil
 }
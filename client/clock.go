@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+)
+
+// Clock represents the Clock sysvar of the cluster.
+type Clock struct {
+	Slot                uint64
+	EpochStartTimestamp int64
+	Epoch               uint64
+	LeaderScheduleEpoch uint64
+	UnixTimestamp       int64
+}
+
+// GetClock returns the Clock sysvar of the cluster at the read commitment of the cluster config.
+func (cb *ContractBackend) GetClock(ctx context.Context) (Clock, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return Clock{}, err
+	}
+	accountInfo, err := rpcClient.GetAccountInfoWithOpts(
+		ctx,
+		solana.SysVarClockPubkey,
+		&rpc.GetAccountInfoOpts{
+			Commitment: cb.cluster.Commitments.Read,
+		},
+	)
+	if err != nil {
+		return Clock{}, errors.Wrap(err, "GetClock: could not get clock sysvar")
+	}
+	var clock Clock
+	if err := bin.NewBinDecoder(accountInfo.Value.Data.GetBinary()).Decode(&clock); err != nil {
+		return Clock{}, errors.Wrap(err, "GetClock: could not decode clock sysvar")
+	}
+	return clock, nil
+}
+
+// GetClusterTime returns the current time of the cluster as reported by the Clock sysvar. It is the time the
+// on-chain program uses to timestamp disputes and to check whether their challenge duration has passed.
+func (cb *ContractBackend) GetClusterTime(ctx context.Context) (time.Time, error) {
+	clock, err := cb.GetClock(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(clock.UnixTimestamp, 0), nil
+}
+
+// ChallengeDeadline returns the cluster time at which the challenge duration of a dispute registered at the given
+// on-chain timestamp ends.
+func ChallengeDeadline(timestamp uint64, challengeDuration uint64) time.Time {
+	return time.Unix(int64(timestamp+challengeDuration), 0) //nolint:gosec
+}
+
+// RemainingUntil returns the time until the cluster time passes the given deadline. It is zero or negative once the
+// deadline has passed.
+func (cb *ContractBackend) RemainingUntil(ctx context.Context, deadline time.Time) (time.Duration, error) {
+	now, err := cb.GetClusterTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// The cluster clock has a resolution of one second, so the deadline is passed only once it is strictly after it.
+	return deadline.Sub(now) + time.Second, nil
+}
@@ -0,0 +1,317 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the cluster presets.
+const (
+	ClusterLocalnet    = "localnet"
+	ClusterDevnet      = "devnet"
+	ClusterTestnet     = "testnet"
+	ClusterMainnetBeta = "mainnet-beta"
+)
+
+// Environment variables read by ClusterConfigFromEnv.
+const (
+	EnvCluster             = "PERUN_SOLANA_CLUSTER"
+	EnvRPCURL              = "PERUN_SOLANA_RPC_URL"
+	EnvWSURL               = "PERUN_SOLANA_WS_URL"
+	EnvPerunProgramID      = "PERUN_SOLANA_PROGRAM_ID"
+	EnvReadCommitment      = "PERUN_SOLANA_READ_COMMITMENT"
+	EnvBlockhashCommitment = "PERUN_SOLANA_BLOCKHASH_COMMITMENT"
+	EnvSubscribeCommitment = "PERUN_SOLANA_SUBSCRIBE_COMMITMENT"
+	EnvConfirmCommitment   = "PERUN_SOLANA_CONFIRM_COMMITMENT"
+	EnvConfirmTimeout      = "PERUN_SOLANA_CONFIRM_TIMEOUT"
+	EnvPollingInterval     = "PERUN_SOLANA_POLLING_INTERVAL"
+	EnvRebroadcastInterval = "PERUN_SOLANA_REBROADCAST_INTERVAL"
+)
+
+const (
+	DefaultConfirmTimeout      = time.Duration(90) * time.Second
+	DefaultPollingInterval     = time.Duration(4) * time.Second
+	DefaultRebroadcastInterval = time.Duration(2) * time.Second
+)
+
+// Duration is a time.Duration that is encoded as a duration string such as "30s" in YAML and JSON.
+type Duration time.Duration
+
+// MarshalText encodes the duration as a duration string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText decodes the duration from a duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// CommitmentConfig configures the commitment used per kind of operation.
+type CommitmentConfig struct {
+	Read      rpc.CommitmentType `yaml:"read" json:"read"`           // Reading accounts and transaction history.
+	Blockhash rpc.CommitmentType `yaml:"blockhash" json:"blockhash"` // Fetching the blockhash of new transactions.
+	Subscribe rpc.CommitmentType `yaml:"subscribe" json:"subscribe"` // Websocket account notifications.
+	Confirm   rpc.CommitmentType `yaml:"confirm" json:"confirm"`     // Considering a sent transaction as landed.
+}
+
+// TimeoutConfig configures the timeouts and intervals of operations.
+type TimeoutConfig struct {
+	Confirm             Duration `yaml:"confirm" json:"confirm"`                         // Waiting for a transaction to be confirmed.
+	PollingInterval     Duration `yaml:"pollingInterval" json:"pollingInterval"`         // Polling the cluster for changes.
+	RebroadcastInterval Duration `yaml:"rebroadcastInterval" json:"rebroadcastInterval"` // Rebroadcasting a pending transaction.
+}
+
+// ClusterConfig configures the Solana cluster and the Perun program the backend connects to.
+type ClusterConfig struct {
+	Name           string           `yaml:"name" json:"name"`
+	RPCURL         string           `yaml:"rpcURL" json:"rpcURL"`
+	WSURL          string           `yaml:"wsURL" json:"wsURL"`
+	PerunProgramID solana.PublicKey `yaml:"perunProgramID" json:"perunProgramID"`
+	Commitments    CommitmentConfig `yaml:"commitments" json:"commitments"`
+	Timeouts       TimeoutConfig    `yaml:"timeouts" json:"timeouts"`
+}
+
+// LocalnetConfig returns the configuration of a local test validator.
+func LocalnetConfig() ClusterConfig {
+	return newPresetConfig(ClusterLocalnet, rpc.LocalNet_RPC, rpc.LocalNet_WS)
+}
+
+// DevnetConfig returns the configuration of the devnet cluster.
+func DevnetConfig() ClusterConfig {
+	return newPresetConfig(ClusterDevnet, rpc.DevNet_RPC, rpc.DevNet_WS)
+}
+
+// TestnetConfig returns the configuration of the testnet cluster.
+func TestnetConfig() ClusterConfig {
+	return newPresetConfig(ClusterTestnet, rpc.TestNet_RPC, rpc.TestNet_WS)
+}
+
+// MainnetBetaConfig returns the configuration of the mainnet-beta cluster.
+func MainnetBetaConfig() ClusterConfig {
+	return newPresetConfig(ClusterMainnetBeta, rpc.MainNetBeta_RPC, rpc.MainNetBeta_WS)
+}
+
+func newPresetConfig(name string, rpcURL string, wsURL string) ClusterConfig {
+	return ClusterConfig{
+		Name:   name,
+		RPCURL: rpcURL,
+		WSURL:  wsURL,
+		Commitments: CommitmentConfig{
+			Read:      rpc.CommitmentFinalized,
+			Blockhash: rpc.CommitmentFinalized,
+			Subscribe: rpc.CommitmentConfirmed,
+			Confirm:   rpc.CommitmentFinalized,
+		},
+		Timeouts: TimeoutConfig{
+			Confirm:             Duration(DefaultConfirmTimeout),
+			PollingInterval:     Duration(DefaultPollingInterval),
+			RebroadcastInterval: Duration(DefaultRebroadcastInterval),
+		},
+	}
+}
+
+// PresetClusterConfig returns the preset configuration of the cluster with the given name.
+func PresetClusterConfig(name string) (ClusterConfig, error) {
+	switch name {
+	case ClusterLocalnet:
+		return LocalnetConfig(), nil
+	case ClusterDevnet:
+		return DevnetConfig(), nil
+	case ClusterTestnet:
+		return TestnetConfig(), nil
+	case ClusterMainnetBeta:
+		return MainnetBetaConfig(), nil
+	default:
+		return ClusterConfig{}, errors.Errorf("unknown cluster %q", name)
+	}
+}
+
+// LoadClusterConfig loads the configuration from the given YAML or JSON file, depending on its extension.
+func LoadClusterConfig(path string) (ClusterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClusterConfig{}, errors.Wrap(err, "could not read cluster config")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseClusterConfigYAML(data)
+	case ".json":
+		return ParseClusterConfigJSON(data)
+	default:
+		return ClusterConfig{}, errors.Errorf("unsupported cluster config format %q", filepath.Ext(path))
+	}
+}
+
+// ParseClusterConfigYAML parses the configuration from YAML. Fields that are not set are taken from the preset
+// named by the name field, or from the localnet preset if no name is set. A cluster with another name is a custom
+// cluster, which has to set both endpoints and takes the other fields from the preset defaults.
+func ParseClusterConfigYAML(data []byte) (ClusterConfig, error) {
+	return parseClusterConfig(data, yaml.Unmarshal)
+}
+
+// ParseClusterConfigJSON parses the configuration from JSON. Fields that are not set are taken from the preset
+// named by the name field, or from the localnet preset if no name is set. A cluster with another name is a custom
+// cluster, which has to set both endpoints and takes the other fields from the preset defaults.
+func ParseClusterConfigJSON(data []byte) (ClusterConfig, error) {
+	return parseClusterConfig(data, json.Unmarshal)
+}
+
+func parseClusterConfig(data []byte, unmarshal func([]byte, any) error) (ClusterConfig, error) {
+	var named struct {
+		Name string `yaml:"name" json:"name"`
+	}
+	if err := unmarshal(data, &named); err != nil {
+		return ClusterConfig{}, errors.Wrap(err, "could not parse cluster config")
+	}
+	cfg := baseClusterConfig(named.Name)
+	if err := unmarshal(data, &cfg); err != nil {
+		return ClusterConfig{}, errors.Wrap(err, "could not parse cluster config")
+	}
+	return cfg, cfg.Validate()
+}
+
+// baseClusterConfig returns the configuration that the cluster config with the given name overrides. This is the
+// preset of that name, the localnet preset if the name is empty, and otherwise a custom cluster without endpoints.
+func baseClusterConfig(name string) ClusterConfig {
+	if name == "" {
+		return LocalnetConfig()
+	}
+	cfg, err := PresetClusterConfig(name)
+	if err != nil {
+		return newPresetConfig(name, "", "")
+	}
+	return cfg
+}
+
+// clusterConfigFromRPCURL returns the configuration of the cluster with the given RPC URL. This is the preset with
+// that URL, the localnet preset if the URL is empty, and otherwise a custom cluster whose websocket endpoint is
+// derived from the RPC URL. Like for a local test validator, its port is the one of the RPC endpoint plus one.
+func clusterConfigFromRPCURL(rpcURL string) ClusterConfig {
+	if rpcURL == "" {
+		return LocalnetConfig()
+	}
+	for _, preset := range []ClusterConfig{LocalnetConfig(), DevnetConfig(), TestnetConfig(), MainnetBetaConfig()} {
+		if preset.RPCURL == rpcURL {
+			return preset
+		}
+	}
+	return newPresetConfig("", rpcURL, wsURLFromRPCURL(rpcURL))
+}
+
+// wsURLFromRPCURL derives the websocket URL of the given RPC URL, or returns an empty URL if it is not an HTTP URL.
+func wsURLFromRPCURL(rpcURL string) string {
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return ""
+	}
+	if port := u.Port(); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return ""
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(p+1))
+	}
+	return u.String()
+}
+
+// ClusterConfigFromEnv loads the configuration from the environment. The preset named by PERUN_SOLANA_CLUSTER, or
+// the localnet preset if it is not set, is overridden by the other PERUN_SOLANA_* variables that are set. Another
+// cluster name names a custom cluster, whose endpoints have to be set by PERUN_SOLANA_RPC_URL and PERUN_SOLANA_WS_URL.
+func ClusterConfigFromEnv() (ClusterConfig, error) {
+	cfg := baseClusterConfig(os.Getenv(EnvCluster))
+
+	if v, ok := os.LookupEnv(EnvRPCURL); ok {
+		cfg.RPCURL = v
+	}
+	if v, ok := os.LookupEnv(EnvWSURL); ok {
+		cfg.WSURL = v
+	}
+	if v, ok := os.LookupEnv(EnvPerunProgramID); ok {
+		programID, err := solana.PublicKeyFromBase58(v)
+		if err != nil {
+			return ClusterConfig{}, errors.Wrapf(err, "invalid %s", EnvPerunProgramID)
+		}
+		cfg.PerunProgramID = programID
+	}
+	if v, ok := os.LookupEnv(EnvReadCommitment); ok {
+		cfg.Commitments.Read = rpc.CommitmentType(v)
+	}
+	if v, ok := os.LookupEnv(EnvBlockhashCommitment); ok {
+		cfg.Commitments.Blockhash = rpc.CommitmentType(v)
+	}
+	if v, ok := os.LookupEnv(EnvSubscribeCommitment); ok {
+		cfg.Commitments.Subscribe = rpc.CommitmentType(v)
+	}
+	if v, ok := os.LookupEnv(EnvConfirmCommitment); ok {
+		cfg.Commitments.Confirm = rpc.CommitmentType(v)
+	}
+	if v, ok := os.LookupEnv(EnvConfirmTimeout); ok {
+		if err := cfg.Timeouts.Confirm.UnmarshalText([]byte(v)); err != nil {
+			return ClusterConfig{}, errors.Wrapf(err, "invalid %s", EnvConfirmTimeout)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvPollingInterval); ok {
+		if err := cfg.Timeouts.PollingInterval.UnmarshalText([]byte(v)); err != nil {
+			return ClusterConfig{}, errors.Wrapf(err, "invalid %s", EnvPollingInterval)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvRebroadcastInterval); ok {
+		if err := cfg.Timeouts.RebroadcastInterval.UnmarshalText([]byte(v)); err != nil {
+			return ClusterConfig{}, errors.Wrapf(err, "invalid %s", EnvRebroadcastInterval)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the configuration is complete.
+func (c ClusterConfig) Validate() error {
+	if c.RPCURL == "" {
+		return errors.New("cluster config: RPC URL is not set")
+	}
+	if c.WSURL == "" {
+		return errors.New("cluster config: websocket URL is not set")
+	}
+	commitments := []rpc.CommitmentType{c.Commitments.Read, c.Commitments.Blockhash, c.Commitments.Subscribe, c.Commitments.Confirm}
+	for _, commitment := range commitments {
+		switch commitment {
+		case rpc.CommitmentProcessed, rpc.CommitmentConfirmed, rpc.CommitmentFinalized:
+		default:
+			return errors.Errorf("cluster config: invalid commitment %q", commitment)
+		}
+	}
+	if c.Timeouts.Confirm <= 0 {
+		return errors.New("cluster config: confirm timeout must be positive")
+	}
+	if c.Timeouts.PollingInterval <= 0 {
+		return errors.New("cluster config: polling interval must be positive")
+	}
+	if c.Timeouts.RebroadcastInterval <= 0 {
+		return errors.New("cluster config: rebroadcast interval must be positive")
+	}
+	return nil
+}
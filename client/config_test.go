@@ -0,0 +1,234 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestParseClusterConfig(t *testing.T) {
+	programID := solana.PublicKey{1}
+	devnet := DevnetConfig()
+	devnet.PerunProgramID = programID
+	devnet.Timeouts.Confirm = Duration(time.Minute)
+	custom := newPresetConfig("private", "http://10.0.0.1:8899", "ws://10.0.0.1:8900")
+	custom.Commitments.Confirm = rpc.CommitmentConfirmed
+
+	tests := []struct {
+		name    string
+		yaml    string
+		json    string
+		want    ClusterConfig
+		wantErr bool
+	}{
+		{name: "empty", yaml: "{}", json: "{}", want: LocalnetConfig()},
+		{
+			name: "preset with overrides",
+			yaml: "name: devnet\nperunProgramID: " + programID.String() + "\ntimeouts:\n  confirm: 1m\n",
+			json: `{"name":"devnet","perunProgramID":"` + programID.String() + `","timeouts":{"confirm":"1m"}}`,
+			want: devnet,
+		},
+		{
+			name: "custom cluster",
+			yaml: "name: private\nrpcURL: http://10.0.0.1:8899\nwsURL: ws://10.0.0.1:8900\ncommitments:\n  confirm: confirmed\n",
+			json: `{"name":"private","rpcURL":"http://10.0.0.1:8899","wsURL":"ws://10.0.0.1:8900","commitments":{"confirm":"confirmed"}}`,
+			want: custom,
+		},
+		{
+			name:    "custom cluster without websocket endpoint",
+			yaml:    "name: private\nrpcURL: http://10.0.0.1:8899\n",
+			json:    `{"name":"private","rpcURL":"http://10.0.0.1:8899"}`,
+			wantErr: true,
+		},
+		{
+			name:    "custom cluster without endpoints",
+			yaml:    "name: private\n",
+			json:    `{"name":"private"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid commitment",
+			yaml:    "commitments:\n  read: recent\n",
+			json:    `{"commitments":{"read":"recent"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "zero confirm timeout",
+			yaml:    "timeouts:\n  confirm: 0s\n",
+			json:    `{"timeouts":{"confirm":"0s"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "zero rebroadcast interval",
+			yaml:    "timeouts:\n  rebroadcastInterval: 0s\n",
+			json:    `{"timeouts":{"rebroadcastInterval":"0s"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			yaml:    "timeouts:\n  confirm: soon\n",
+			json:    `{"timeouts":{"confirm":"soon"}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		for format, parse := range map[string]func([]byte) (ClusterConfig, error){
+			"yaml": ParseClusterConfigYAML,
+			"json": ParseClusterConfigJSON,
+		} {
+			data := tt.yaml
+			if format == "json" {
+				data = tt.json
+			}
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				got, err := parse([]byte(data))
+				if tt.wantErr {
+					if err == nil {
+						t.Fatalf("got config %+v, want error", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Fatalf("got config %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadClusterConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		file    string
+		data    string
+		wantErr bool
+	}{
+		{file: "cluster.yaml", data: "name: testnet\n"},
+		{file: "cluster.YML", data: "name: testnet\n"},
+		{file: "cluster.json", data: `{"name":"testnet"}`},
+		{file: "cluster.toml", data: `name = "testnet"`, wantErr: true},
+		{file: "missing.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if tt.data != "" {
+				if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := LoadClusterConfig(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got config %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != TestnetConfig() {
+				t.Fatalf("got config %+v, want %+v", got, TestnetConfig())
+			}
+		})
+	}
+}
+
+func TestClusterConfigFromEnv(t *testing.T) {
+	programID := solana.PublicKey{1}
+	mainnet := MainnetBetaConfig()
+	mainnet.RPCURL = "https://rpc.example.com"
+	mainnet.PerunProgramID = programID
+	mainnet.Commitments.Subscribe = rpc.CommitmentFinalized
+	mainnet.Timeouts.PollingInterval = Duration(time.Second)
+	custom := newPresetConfig("private", "http://10.0.0.1:8899", "ws://10.0.0.1:8900")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    ClusterConfig
+		wantErr bool
+	}{
+		{name: "unset", want: LocalnetConfig()},
+		{
+			name: "preset with overrides",
+			env: map[string]string{
+				EnvCluster:             ClusterMainnetBeta,
+				EnvRPCURL:              "https://rpc.example.com",
+				EnvPerunProgramID:      programID.String(),
+				EnvSubscribeCommitment: "finalized",
+				EnvPollingInterval:     "1s",
+			},
+			want: mainnet,
+		},
+		{
+			name: "custom cluster",
+			env:  map[string]string{EnvCluster: "private", EnvRPCURL: "http://10.0.0.1:8899", EnvWSURL: "ws://10.0.0.1:8900"},
+			want: custom,
+		},
+		{
+			name:    "custom cluster without websocket endpoint",
+			env:     map[string]string{EnvCluster: "private", EnvRPCURL: "http://10.0.0.1:8899"},
+			wantErr: true,
+		},
+		{name: "invalid program ID", env: map[string]string{EnvPerunProgramID: "invalid"}, wantErr: true},
+		{name: "invalid commitment", env: map[string]string{EnvConfirmCommitment: "recent"}, wantErr: true},
+		{name: "invalid confirm timeout", env: map[string]string{EnvConfirmTimeout: "soon"}, wantErr: true},
+		{name: "negative rebroadcast interval", env: map[string]string{EnvRebroadcastInterval: "-1s"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{
+				EnvCluster, EnvRPCURL, EnvWSURL, EnvPerunProgramID, EnvReadCommitment, EnvBlockhashCommitment,
+				EnvSubscribeCommitment, EnvConfirmCommitment, EnvConfirmTimeout, EnvPollingInterval, EnvRebroadcastInterval,
+			} {
+				t.Setenv(name, "")
+				os.Unsetenv(name) //nolint:errcheck
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			got, err := ClusterConfigFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got config %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got config %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterConfigFromRPCURL(t *testing.T) {
+	tests := []struct {
+		rpcURL string
+		want   ClusterConfig
+	}{
+		{rpcURL: "", want: LocalnetConfig()},
+		{rpcURL: rpc.DevNet_RPC, want: DevnetConfig()},
+		{rpcURL: rpc.MainNetBeta_RPC, want: MainnetBetaConfig()},
+		{rpcURL: "http://10.0.0.1:8899", want: newPresetConfig("", "http://10.0.0.1:8899", "ws://10.0.0.1:8900")},
+		{rpcURL: "https://rpc.example.com/key", want: newPresetConfig("", "https://rpc.example.com/key", "wss://rpc.example.com/key")},
+		{rpcURL: "unix:///tmp/rpc", want: newPresetConfig("", "unix:///tmp/rpc", "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rpcURL, func(t *testing.T) {
+			if got := clusterConfigFromRPCURL(tt.rpcURL); got != tt.want {
+				t.Fatalf("got config %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 
@@ -12,17 +13,10 @@ import (
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
-	"github.com/gagliardetto/solana-go/rpc/ws"
 	"github.com/perun-network/perun-solana-backend/channel"
 	"github.com/perun-network/perun-solana-backend/wallet"
 )
 
-const (
-	defaultSolanaRPC  = rpc.LocalNet_RPC // Default Solana RPC endpoint.
-	defaultSolanaWS   = rpc.LocalNet_WS  // Default Solana WebSocket endpoint.
-	defaultCommitment = rpc.CommitmentFinalized
-)
-
 type SolanaSigner struct {
 	privateKey  *solana.PrivateKey // The private key of the account that will be used to sign transactions.
 	participant *wallet.Participant
@@ -58,6 +52,8 @@ func NewSignerConfig(
 	return signerConfig
 }
 
+// NewRandomConfig creates a new SignerConfig with a random account. It sets no RPC endpoint, which is taken from the
+// cluster the contract backend connects to, see NewContractBackendWithCluster.
 func NewRandomConfig(rng *rand.Rand) *SignerConfig {
 	signerConfig := &SignerConfig{}
 
@@ -69,14 +65,11 @@ func NewRandomConfig(rng *rand.Rand) *SignerConfig {
 	signerConfig.account = acc
 	signerConfig.participant = acc.Participant()
 	signerConfig.privateKey = kp
-	// Set the default RPC URL.
-	signerConfig.rpcURL = defaultSolanaRPC
-	// Create a new TxSender with the default RPC URL.
-	signerConfig.sender = NewTxSender(rpc.New(defaultSolanaRPC))
 	return signerConfig
 }
 
-// NewSolanaSigner creates a new SolanaSigner with the provided configuration.
+// NewSolanaSigner creates a new SolanaSigner with the provided configuration. If the configuration sets neither a
+// sender nor an RPC URL, sending transactions fails with ErrNoRPCEndpoint.
 func NewSolanaSigner(cfg SignerConfig) *SolanaSigner {
 	ss := &SolanaSigner{}
 
@@ -92,11 +85,10 @@ func NewSolanaSigner(cfg SignerConfig) *SolanaSigner {
 
 	if cfg.sender != nil {
 		ss.sender = cfg.sender
-	} else {
-		if cfg.rpcURL == "" {
-			cfg.rpcURL = defaultSolanaRPC // Use the default RPC URL if none is provided.
-		}
+	} else if cfg.rpcURL != "" {
 		ss.sender = NewTxSender(rpc.New(cfg.rpcURL))
+	} else {
+		ss.sender = NewTxSender(nil)
 	}
 
 	return ss
@@ -106,72 +98,159 @@ func NewSolanaSigner(cfg SignerConfig) *SolanaSigner {
 type ContractBackend struct {
 	signer  SolanaSigner
 	chainID int
+	cluster ClusterConfig // The cluster the backend connects to.
 	cbMutex sync.Mutex
+
+	preflightMtx  sync.Mutex
+	skipPreflight bool // Whether transactions are sent without simulating them first, see SetPreflight.
+
+	wrapSOLMtx sync.Mutex
+	wrapSOL    bool // Whether native SOL is moved as wrapped SOL, see SetWrapSOL.
+
+	lookupMtx    sync.Mutex
+	lookupTables solana.PublicKeySlice // The address lookup tables of v0 transactions, see AddLookupTables.
+
+	feePayerMtx sync.Mutex
+	feePayerKey *solana.PrivateKey // The account paying the fees if not the participant, see SetFeePayer.
+
+	feeMtx        sync.Mutex
+	feePolicy     FeePolicy               // The fee policy of operations without a dedicated policy.
+	opFeePolicies map[Operation]FeePolicy // The dedicated fee policies of operations.
 }
 
-// NewRandomDefaultContractBackend creates a new ContractBackend with a random signer configuration and the default chain ID.
+// NewRandomDefaultContractBackend creates a new ContractBackend with a random signer configuration and the default chain
+// ID, which connects to a local test validator.
 func NewRandomDefaultContractBackend() *ContractBackend {
 	rng := rand.New(rand.NewSource(rand.Int63()))
-	return NewContractBackend(*NewRandomConfig(rng), channel.BackendID)
+	cb, err := NewContractBackendWithCluster(*NewRandomConfig(rng), channel.BackendID, LocalnetConfig())
+	if err != nil {
+		panic(err)
+	}
+	return cb
 }
 
-// NewContractBackend creates a new ContractBackend with the given signer configuration and chain ID.
+// NewContractBackend creates a new ContractBackend with the given signer configuration and chain ID. It connects to
+// the preset cluster with the RPC URL of the signer configuration, to a local test validator if no RPC URL is set, and
+// otherwise to a custom cluster whose websocket endpoint is derived from the RPC URL.
+//
+// Deprecated: Use NewContractBackendWithCluster, which takes the endpoints and the Perun program ID from the given
+// cluster config.
 func NewContractBackend(scfg SignerConfig, chainID int) *ContractBackend {
+	cluster := clusterConfigFromRPCURL(scfg.rpcURL)
+	if scfg.sender == nil && scfg.rpcURL == "" {
+		scfg.rpcURL = cluster.RPCURL
+	}
 	cb := &ContractBackend{
 		signer:  *NewSolanaSigner(scfg),
 		chainID: chainID,
+		cluster: cluster,
 		cbMutex: sync.Mutex{},
 	}
 
 	return cb
 }
 
-func (cb *ContractBackend) InvokeSignedTx(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
-	cb.cbMutex.Lock()
-	defer cb.cbMutex.Unlock()
+// NewContractBackendWithCluster creates a new ContractBackend with the given signer configuration and chain ID, which
+// connects to the given cluster. The RPC URL of the cluster is used unless the signer configuration sets a sender or
+// an RPC URL.
+func NewContractBackendWithCluster(scfg SignerConfig, chainID int, cluster ClusterConfig) (*ContractBackend, error) {
+	if err := cluster.Validate(); err != nil {
+		return nil, err
+	}
+	if scfg.sender == nil && scfg.rpcURL == "" {
+		scfg.rpcURL = cluster.RPCURL
+	}
+	cb := &ContractBackend{
+		signer:  *NewSolanaSigner(scfg),
+		chainID: chainID,
+		cluster: cluster,
+		cbMutex: sync.Mutex{},
+	}
 
-	_, err := tx.Sign(
-		func(key solana.PublicKey) *solana.PrivateKey {
-			if cb.signer.privateKey.PublicKey() == key {
-				return cb.signer.privateKey
-			}
-			return nil
-		},
-	)
-	if err != nil {
-		return solana.Signature{}, errors.Wrap(err, "InvokeTx: could not sign transaction")
+	return cb, nil
+}
+
+// rpcClient returns the RPC client of the sender or ErrNoRPCEndpoint if no RPC endpoint is configured.
+func (cb *ContractBackend) rpcClient() (*rpc.Client, error) {
+	rpcClient := cb.signer.sender.GetRPCClient()
+	if rpcClient == nil {
+		return nil, ErrNoRPCEndpoint
+	}
+	return rpcClient, nil
+}
+
+// ClusterConfig returns the configuration of the cluster the backend connects to.
+func (cb *ContractBackend) ClusterConfig() ClusterConfig {
+	return cb.cluster
+}
+
+func (cb *ContractBackend) InvokeSignedTx(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	if err := cb.signTx(tx); err != nil {
+		return solana.Signature{}, errors.Wrap(err, "InvokeTx")
 	}
 
 	return cb.signer.sender.SendTx(ctx, tx)
 }
 
+// InvokeAndConfirmSignedTx signs the given transaction, simulates it unless the preflight is disabled, and broadcasts
+// it at the rebroadcast interval of the cluster config until it is confirmed, at most for the confirm timeout. The
+// transaction is not re-signed, as its blockhash may be a durable nonce. If the simulation fails, the returned error
+// wraps a *SimulationError.
 func (cb *ContractBackend) InvokeAndConfirmSignedTx(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	if err := cb.signTx(tx); err != nil {
+		return solana.Signature{}, errors.Wrap(err, "InvokeAndConfirmTx")
+	}
+	if cb.preflight() {
+		if _, err := cb.SimulateTx(ctx, tx); err != nil {
+			return solana.Signature{}, errors.Wrap(err, "InvokeAndConfirmTx: preflight failed")
+		}
+	}
+
+	ctx, cancel := cb.confirmContext(ctx)
+	defer cancel()
+	sig, _, err := cb.broadcastUntilExpired(ctx, tx, math.MaxUint64)
+	if err != nil {
+		return sig, errors.Wrap(err, "InvokeAndConfirmTx")
+	}
+	return sig, nil
+}
+
+// signTx signs the given transaction and checks its size. The backend mutex is only held while signing, so that other
+// transactions can be signed while the transaction is awaited.
+func (cb *ContractBackend) signTx(tx *solana.Transaction) error {
 	cb.cbMutex.Lock()
 	defer cb.cbMutex.Unlock()
-	wsClient, err := ws.Connect(ctx, rpc.LocalNet_WS)
-	if err != nil {
-		return solana.Signature{}, errors.Wrap(err, "InvokeAndConfirmTx: could not connect to WebSocket client")
-	}
-	_, err = tx.Sign(
-		func(key solana.PublicKey) *solana.PrivateKey {
-			if cb.signer.privateKey.PublicKey() == key {
-				return cb.signer.privateKey
-			}
-			return nil
-		},
-	)
+
+	if _, err := tx.Sign(cb.signerKeys(nil)); err != nil {
+		return errors.Wrap(err, "could not sign transaction")
+	}
+	return checkTxSize(tx)
+}
+
+// invokeInstructions submits the given instructions as a transaction of the given operation paid by the fee payer and
+// waits for its confirmation, see submitInstructions. The compute budget instructions of the fee policy of the
+// operation are prepended.
+func (cb *ContractBackend) invokeInstructions(ctx context.Context, op Operation, instructions ...solana.Instruction) error {
+	budgetIxs, err := cb.computeBudgetInstructions(ctx, op, instructions)
 	if err != nil {
-		return solana.Signature{}, errors.Wrap(err, "InvokeAndConfirmTx: could not sign transaction")
+		return err
 	}
+	instructions = append(budgetIxs, instructions...)
 
-	return cb.signer.sender.SendAndConfirmTx(ctx, tx, wsClient)
+	if _, err := cb.submitInstructions(ctx, instructions); err != nil {
+		return errors.Wrapf(err, "could not submit %s transaction", op)
+	}
+	return nil
 }
 
 // GetBalance returns the balance of the given asset mint.
 // If the mint is the zero pubkey, it returns the SOL balance.
 func (cb *ContractBackend) GetBalance(mint solana.PublicKey) (string, error) {
 	ctx := context.Background()
-	client := cb.signer.sender.GetRPCClient()
+	client, err := cb.rpcClient()
+	if err != nil {
+		return "", err
+	}
 
 	// Check if mint is zero => SOL balance
 	if mint.IsZero() {
@@ -185,13 +264,17 @@ func (cb *ContractBackend) GetBalance(mint solana.PublicKey) (string, error) {
 		return fmt.Sprintf("%d", acctInfo.Value.Lamports), nil
 	}
 
-	// Otherwise, treat it as an SPL token and get ATA balance
-	ata, _, err := solana.FindAssociatedTokenAddress(cb.signer.participant.SolanaAddress, mint)
+	// Otherwise, treat it as an SPL token of the token program owning the mint and get ATA balance
+	mintInfo, err := cb.GetMintInfo(ctx, mint)
+	if err != nil {
+		return "", fmt.Errorf("failed to get mint info: %w", err)
+	}
+	ata, err := AssociatedTokenAddress(cb.signer.participant.SolanaAddress, mint, mintInfo.TokenProgram)
 	if err != nil {
 		return "", fmt.Errorf("failed to derive ATA: %w", err)
 	}
 
-	res, err := client.GetTokenAccountBalance(ctx, ata, rpc.CommitmentFinalized)
+	res, err := client.GetTokenAccountBalance(ctx, ata, cb.cluster.Commitments.Read)
 	if err != nil {
 		return "", fmt.Errorf("failed to get SPL token balance: %w", err)
 	}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	computebudget "github.com/gagliardetto/solana-go/programs/compute-budget"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+)
+
+// Operation is the kind of channel operation a transaction performs.
+type Operation int
+
+const (
+	OperationOpen Operation = iota
+	OperationFund
+	OperationAbort
+	OperationDispute
+	OperationProgress
+	OperationClose
+	OperationForceClose
+	OperationWithdraw
+	OperationOpenAndFund
+)
+
+// String returns the name of the operation.
+func (op Operation) String() string {
+	switch op {
+	case OperationOpen:
+		return "open"
+	case OperationFund:
+		return "fund"
+	case OperationAbort:
+		return "abort"
+	case OperationDispute:
+		return "dispute"
+	case OperationProgress:
+		return "progress"
+	case OperationClose:
+		return "close"
+	case OperationForceClose:
+		return "force close"
+	case OperationWithdraw:
+		return "withdraw"
+	case OperationOpenAndFund:
+		return "open and fund"
+	default:
+		return "unknown"
+	}
+}
+
+// ComputeBudget is the compute budget requested by a transaction.
+type ComputeBudget struct {
+	UnitLimit uint32 // The compute unit limit, zero to use the default limit of the cluster.
+	UnitPrice uint64 // The priority fee in micro-lamports per compute unit, zero to pay no priority fee.
+}
+
+// FeePolicy determines the compute budget of a transaction writing to the given accounts.
+type FeePolicy func(ctx context.Context, rpcClient *rpc.Client, writableAccounts solana.PublicKeySlice) (ComputeBudget, error)
+
+// FixedFeePolicy returns a FeePolicy that always requests the given compute unit limit and price.
+func FixedFeePolicy(unitLimit uint32, unitPrice uint64) FeePolicy {
+	return func(context.Context, *rpc.Client, solana.PublicKeySlice) (ComputeBudget, error) {
+		return ComputeBudget{UnitLimit: unitLimit, UnitPrice: unitPrice}, nil
+	}
+}
+
+// PercentileFeePolicy returns a FeePolicy that requests the given compute unit limit and pays the given percentile of
+// the priority fees recently paid for the accounts the transaction writes to, but at most maxUnitPrice if it is not
+// zero.
+func PercentileFeePolicy(unitLimit uint32, percentile int, maxUnitPrice uint64) FeePolicy {
+	return func(ctx context.Context, rpcClient *rpc.Client, writableAccounts solana.PublicKeySlice) (ComputeBudget, error) {
+		fees, err := rpcClient.GetRecentPrioritizationFees(ctx, writableAccounts)
+		if err != nil {
+			return ComputeBudget{}, errors.Wrap(err, "could not get recent prioritization fees")
+		}
+		price := feePercentile(fees, percentile)
+		if maxUnitPrice != 0 && price > maxUnitPrice {
+			price = maxUnitPrice
+		}
+		return ComputeBudget{UnitLimit: unitLimit, UnitPrice: price}, nil
+	}
+}
+
+// feePercentile returns the given percentile of the given fees, or zero if there are none.
+func feePercentile(fees []rpc.PriorizationFeeResult, percentile int) uint64 {
+	if len(fees) == 0 {
+		return 0
+	}
+	values := make([]uint64, len(fees))
+	for i, fee := range fees {
+		values[i] = fee.PrioritizationFee
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	percentile = max(0, min(percentile, 100))  //nolint:gomnd
+	idx := (percentile*len(values)+99)/100 - 1 //nolint:gomnd
+	return values[max(idx, 0)]
+}
+
+// SetFeePolicy sets the fee policy used for all operations without a dedicated policy. A nil policy requests no
+// compute budget.
+func (cb *ContractBackend) SetFeePolicy(policy FeePolicy) {
+	cb.feeMtx.Lock()
+	defer cb.feeMtx.Unlock()
+	cb.feePolicy = policy
+}
+
+// SetOperationFeePolicy sets the fee policy used for the given operation. A nil policy removes the dedicated policy,
+// so that the policy set by SetFeePolicy is used again.
+func (cb *ContractBackend) SetOperationFeePolicy(op Operation, policy FeePolicy) {
+	cb.feeMtx.Lock()
+	defer cb.feeMtx.Unlock()
+	if policy == nil {
+		delete(cb.opFeePolicies, op)
+		return
+	}
+	if cb.opFeePolicies == nil {
+		cb.opFeePolicies = make(map[Operation]FeePolicy)
+	}
+	cb.opFeePolicies[op] = policy
+}
+
+// SetDisputeFeePolicy sets the fee policy used for the time-critical operations dispute, progress and force close,
+// which usually warrant a more aggressive policy than funding.
+func (cb *ContractBackend) SetDisputeFeePolicy(policy FeePolicy) {
+	for _, op := range []Operation{OperationDispute, OperationProgress, OperationForceClose} {
+		cb.SetOperationFeePolicy(op, policy)
+	}
+}
+
+func (cb *ContractBackend) feePolicyFor(op Operation) FeePolicy {
+	cb.feeMtx.Lock()
+	defer cb.feeMtx.Unlock()
+	if policy, ok := cb.opFeePolicies[op]; ok {
+		return policy
+	}
+	return cb.feePolicy
+}
+
+// computeBudgetInstructions returns the compute budget instructions to prepend to a transaction of the given
+// operation consisting of the given instructions.
+func (cb *ContractBackend) computeBudgetInstructions(ctx context.Context, op Operation, instructions []solana.Instruction) ([]solana.Instruction, error) {
+	policy := cb.feePolicyFor(op)
+	if policy == nil {
+		return nil, nil
+	}
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	budget, err := policy(ctx, rpcClient, writableAccounts(instructions))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not determine compute budget for %s", op)
+	}
+
+	var budgetIxs []solana.Instruction
+	if budget.UnitLimit != 0 {
+		limitIx, err := computebudget.NewSetComputeUnitLimitInstruction(budget.UnitLimit).ValidateAndBuild()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create compute unit limit instruction")
+		}
+		budgetIxs = append(budgetIxs, limitIx)
+	}
+	if budget.UnitPrice != 0 {
+		priceIx, err := computebudget.NewSetComputeUnitPriceInstruction(budget.UnitPrice).ValidateAndBuild()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create compute unit price instruction")
+		}
+		budgetIxs = append(budgetIxs, priceIx)
+	}
+	return budgetIxs, nil
+}
+
+// writableAccounts returns the distinct accounts the given instructions write to.
+func writableAccounts(instructions []solana.Instruction) solana.PublicKeySlice {
+	var accounts solana.PublicKeySlice
+	for _, ix := range instructions {
+		for _, meta := range ix.Accounts() {
+			if meta.IsWritable {
+				accounts.UniqueAppend(meta.PublicKey)
+			}
+		}
+	}
+	return accounts
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/perun-network/perun-solana-backend/channel"
+	"github.com/pkg/errors"
+)
+
+func TestFeePercentile(t *testing.T) {
+	fees := func(values ...uint64) []rpc.PriorizationFeeResult {
+		results := make([]rpc.PriorizationFeeResult, len(values))
+		for i, value := range values {
+			results[i] = rpc.PriorizationFeeResult{Slot: uint64(i), PrioritizationFee: value}
+		}
+		return results
+	}
+
+	tests := []struct {
+		name       string
+		fees       []rpc.PriorizationFeeResult
+		percentile int
+		want       uint64
+	}{
+		{name: "no fees", percentile: 50, want: 0},
+		{name: "single fee", fees: fees(7), percentile: 50, want: 7},
+		{name: "median of unsorted fees", fees: fees(30, 10, 20), percentile: 50, want: 20},
+		{name: "upper percentile rounds up", fees: fees(40, 10, 30, 20), percentile: 51, want: 30},
+		{name: "lower percentile", fees: fees(40, 10, 30, 20), percentile: 25, want: 10},
+		{name: "zero percentile", fees: fees(40, 10, 30, 20), percentile: 0, want: 10},
+		{name: "maximum", fees: fees(40, 10, 30, 20), percentile: 100, want: 40},
+		{name: "negative percentile", fees: fees(40, 10, 30, 20), percentile: -5, want: 10},
+		{name: "percentile above 100", fees: fees(40, 10, 30, 20), percentile: 150, want: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := feePercentile(tt.fees, tt.percentile); got != tt.want {
+				t.Fatalf("got fee %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeBudgetInstructions(t *testing.T) {
+	cb, err := NewContractBackendWithCluster(*NewRandomConfig(rand.New(rand.NewSource(1))), channel.BackendID, LocalnetConfig()) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := func(context.Context, *rpc.Client, solana.PublicKeySlice) (ComputeBudget, error) {
+		return ComputeBudget{}, errors.New("no fees")
+	}
+
+	tests := []struct {
+		name      string
+		policy    FeePolicy
+		opPolicy  FeePolicy // The policy of the dispute operation.
+		op        Operation
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "no policy", op: OperationFund},
+		{name: "limit and price", policy: FixedFeePolicy(200_000, 10), op: OperationFund, wantCount: 2},
+		{name: "limit only", policy: FixedFeePolicy(200_000, 0), op: OperationFund, wantCount: 1},
+		{name: "price only", policy: FixedFeePolicy(0, 10), op: OperationFund, wantCount: 1},
+		{name: "empty budget", policy: FixedFeePolicy(0, 0), op: OperationFund},
+		{name: "operation policy", opPolicy: FixedFeePolicy(200_000, 10), op: OperationDispute, wantCount: 2},
+		{name: "other operation", opPolicy: FixedFeePolicy(200_000, 10), op: OperationFund},
+		{
+			name:      "operation policy overrides default",
+			policy:    failing,
+			opPolicy:  FixedFeePolicy(0, 10),
+			op:        OperationDispute,
+			wantCount: 1,
+		},
+		{name: "failing policy", policy: failing, op: OperationFund, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb.SetFeePolicy(tt.policy)
+			cb.SetDisputeFeePolicy(tt.opPolicy)
+			got, err := cb.computeBudgetInstructions(context.Background(), tt.op, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != tt.wantCount {
+				t.Fatalf("got %d compute budget instructions, want %d", len(got), tt.wantCount)
+			}
+			for _, ix := range got {
+				if !ix.ProgramID().Equals(solana.ComputeBudget) {
+					t.Fatalf("got instruction of program %s, want compute budget program", ix.ProgramID())
+				}
+			}
+		})
+	}
+}
+
+func TestWritableAccounts(t *testing.T) {
+	a, b, c := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}
+	instructions := []solana.Instruction{
+		solana.NewInstruction(solana.PublicKey{9}, solana.AccountMetaSlice{solana.Meta(a).WRITE(), solana.Meta(b)}, nil),
+		solana.NewInstruction(solana.PublicKey{9}, solana.AccountMetaSlice{solana.Meta(c).WRITE(), solana.Meta(a).WRITE()}, nil),
+	}
+	got := writableAccounts(instructions)
+	if want := (solana.PublicKeySlice{a, c}); !got.Equals(want) {
+		t.Fatalf("got writable accounts %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,427 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"slices"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/perun-network/perun-solana-backend/encoding"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+	pwallet "perun.network/go-perun/wallet"
+)
+
+// ErrInvalidTx is returned by ValidateTx for transactions the participant must not co-sign.
+var ErrInvalidTx = errors.New("invalid transaction")
+
+// SetFeePayer sets the account paying the fees of the transactions of the backend, which then have to be signed by
+// both the fee payer and the participant. A nil fee payer lets the participant pay the fees again. Fees can also be
+// sponsored by a fee payer without sharing its key, by co-signing its transactions with CoSignTx.
+func (cb *ContractBackend) SetFeePayer(feePayer *solana.PrivateKey) {
+	cb.feePayerMtx.Lock()
+	defer cb.feePayerMtx.Unlock()
+	cb.feePayerKey = feePayer
+}
+
+// FeePayer returns the account paying the fees of the transactions of the backend.
+func (cb *ContractBackend) FeePayer() solana.PublicKey {
+	cb.feePayerMtx.Lock()
+	defer cb.feePayerMtx.Unlock()
+	if cb.feePayerKey != nil {
+		return cb.feePayerKey.PublicKey()
+	}
+	return cb.signer.privateKey.PublicKey()
+}
+
+// feePayer returns the private key of the fee payer set by SetFeePayer, or nil.
+func (cb *ContractBackend) feePayer() *solana.PrivateKey {
+	cb.feePayerMtx.Lock()
+	defer cb.feePayerMtx.Unlock()
+	return cb.feePayerKey
+}
+
+// ExpectedTx describes the channel operation the participant expects a transaction built by a fee payer to perform,
+// see ValidateTx. The Expected*Tx functions describe the operations that depend on the channel state, the Perun
+// instructions of the other operations are built with the New*Instruction functions.
+type ExpectedTx struct {
+	Operation Operation   // The channel operation performed by the Perun instructions.
+	ChannelID pchannel.ID // The channel the Perun instructions concern.
+
+	// Instructions are the Perun instructions of the operation as the participant builds them. The transaction must
+	// contain exactly these, with the same data and the same accounts in the same order.
+	Instructions []solana.Instruction
+}
+
+// ExpectedFundTx returns the expected funding by the given party of the channel with the given state, which transfers
+// the balance of the party including transfer fees.
+func (cb *ContractBackend) ExpectedFundTx(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State, funderIdx bool) (ExpectedTx, error) {
+	balances, err := encoding.MakeBalances(state.Allocation)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedFundTx: could not encode balances")
+	}
+	instructions, err := cb.fundInstructions(ctx, perunAddr, state.ID, balances, funderIdx)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedFundTx")
+	}
+	return newExpectedTx(OperationFund, state.ID, perunAddr, instructions...), nil
+}
+
+// ExpectedOpenAndFundTx returns the expected opening of the channel with the given parameters and state together with
+// its funding by party A, see OpenAndFund.
+func (cb *ContractBackend) ExpectedOpenAndFundTx(ctx context.Context, perunAddr solana.PublicKey, params *pchannel.Params, state *pchannel.State) (ExpectedTx, error) {
+	openIx, err := cb.NewOpenInstruction(perunAddr, params, state)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedOpenAndFundTx: could not create open instruction")
+	}
+	fundTx, err := cb.ExpectedFundTx(ctx, perunAddr, state, false)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedOpenAndFundTx")
+	}
+	instructions := append([]solana.Instruction{openIx}, fundTx.Instructions...)
+	return newExpectedTx(OperationOpenAndFund, state.ID, perunAddr, instructions...), nil
+}
+
+// ExpectedDisputeTx returns the expected registration of the given state and sub-channel states, see Dispute.
+func (cb *ContractBackend) ExpectedDisputeTx(perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig, subChannels []pchannel.SignedState) (ExpectedTx, error) {
+	disputeIx, err := cb.NewDisputeInstruction(perunAddr, state, sigs, subChannels)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedDisputeTx: could not create dispute instruction")
+	}
+	return newExpectedTx(OperationDispute, state.ID, perunAddr, disputeIx), nil
+}
+
+// ExpectedCloseTx returns the expected conclusion of the channel with the given final state, see Close.
+func (cb *ContractBackend) ExpectedCloseTx(perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig) (ExpectedTx, error) {
+	closeIx, err := cb.NewCloseInstruction(perunAddr, state, sigs)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedCloseTx: could not create close instruction")
+	}
+	return newExpectedTx(OperationClose, state.ID, perunAddr, closeIx), nil
+}
+
+// ExpectedProgressTx returns the expected progression of the channel to the given state, see Progress.
+func (cb *ContractBackend) ExpectedProgressTx(perunAddr solana.PublicKey, appProgram solana.PublicKey, state *pchannel.State, actorIdx bool, sig pwallet.Sig) (ExpectedTx, error) {
+	progressIx, err := cb.NewProgressInstruction(perunAddr, appProgram, state, actorIdx, sig)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedProgressTx: could not create progress instruction")
+	}
+	return newExpectedTx(OperationProgress, state.ID, perunAddr, progressIx), nil
+}
+
+// ExpectedWithdrawTx returns the expected payout of the given party of the closed channel to the receivers of the
+// channel, see Withdraw.
+func (cb *ContractBackend) ExpectedWithdrawTx(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID, partyIdx bool, oneWithdrawer bool) (ExpectedTx, error) {
+	instructions, err := cb.withdrawInstructions(ctx, perunAddr, chanID, partyIdx, oneWithdrawer)
+	if err != nil {
+		return ExpectedTx{}, errors.Wrap(err, "ExpectedWithdrawTx")
+	}
+	return newExpectedTx(OperationWithdraw, chanID, perunAddr, instructions...), nil
+}
+
+// newExpectedTx returns the expected transaction of the given operation with the instructions of the Perun program
+// among the given instructions.
+func newExpectedTx(op Operation, chanID pchannel.ID, perunAddr solana.PublicKey, instructions ...solana.Instruction) ExpectedTx {
+	expected := ExpectedTx{Operation: op, ChannelID: chanID}
+	for _, ix := range instructions {
+		if ix.ProgramID().Equals(perunAddr) {
+			expected.Instructions = append(expected.Instructions, ix)
+		}
+	}
+	return expected
+}
+
+// ValidateTx checks that the given transaction built by a fee payer only moves the funds of the participant as the
+// expected channel operation of the Perun program at the given address does, so that the participant can co-sign it:
+//   - The participant does not pay the fees.
+//   - The Perun instructions are exactly the expected instructions, with the same data and accounts.
+//   - Compute budget instructions are allowed.
+//   - Instructions of the associated token account program that use the participant are only allowed for creating
+//     the token accounts of the participant or of the expected channel.
+//   - Instructions of the system program and the token programs that use the participant or its temporary wrapped SOL
+//     account of the expected channel are only allowed for creating and initializing that account, for closing it
+//     into the participant and for advancing a durable nonce. A created temporary account must be closed again.
+//   - Instructions of any other program must not use the account of the participant.
+//
+// The address lookup tables of a v0 transaction are fetched to resolve the accounts it loads from them, unless they
+// are set already.
+func (cb *ContractBackend) ValidateTx(ctx context.Context, tx *solana.Transaction, perunAddr solana.PublicKey, expected ExpectedTx) error {
+	participant := cb.signer.privateKey.PublicKey()
+	msg := &tx.Message
+	if len(msg.AccountKeys) == 0 || int(msg.Header.NumRequiredSignatures) > len(msg.AccountKeys) {
+		return errors.Wrap(ErrInvalidTx, "malformed message")
+	}
+	if len(expected.Instructions) == 0 {
+		return errors.Errorf("no expected Perun instructions of %s", expected.Operation)
+	}
+	if err := cb.setLookupTables(ctx, msg); err != nil {
+		return errors.Wrap(err, "could not resolve lookup tables")
+	}
+	signers := msg.AccountKeys[:msg.Header.NumRequiredSignatures]
+	if signers[0].Equals(participant) {
+		return errors.Wrap(ErrInvalidTx, "participant pays the fees")
+	}
+	participantIdx := -1
+	for i, signer := range signers {
+		if signer.Equals(participant) {
+			participantIdx = i
+		}
+	}
+	if participantIdx < 0 {
+		return errors.Wrap(ErrInvalidTx, "participant is not a signer")
+	}
+	channelPDA, err := ChannelPDA(expected.ChannelID, perunAddr)
+	if err != nil {
+		return err
+	}
+	tempAccount, _, err := TemporaryWrappedSOLAccount(participant, expected.ChannelID)
+	if err != nil {
+		return err
+	}
+	missing := slices.Clone(expected.Instructions)
+
+	var tempState wrappedSOLAccountState
+	for i, ix := range msg.Instructions {
+		programID, err := msg.Program(ix.ProgramIDIndex)
+		if err != nil {
+			return errors.Wrapf(ErrInvalidTx, "instruction %d: unknown program", i)
+		}
+		if programID.Equals(perunAddr) {
+			if err := validatePerunInstruction(msg, ix, expected.Operation, &missing); err != nil {
+				return errors.Wrapf(ErrInvalidTx, "instruction %d: %v", i, err)
+			}
+			continue
+		}
+		if !usesAccount(ix, participantIdx) && !usesKey(msg, ix, tempAccount) {
+			continue
+		}
+		switch {
+		case programID.Equals(solana.ComputeBudget):
+		case programID.Equals(solana.SPLAssociatedTokenAccountProgramID):
+			err = validateCreateTokenAccountInstruction(msg, ix, participant, channelPDA)
+		case programID.Equals(solana.SystemProgramID):
+			err = validateSystemInstruction(msg, ix, participant, tempAccount, &tempState)
+		case programID.Equals(solana.TokenProgramID), programID.Equals(solana.Token2022ProgramID):
+			err = validateTokenInstruction(msg, ix, participant, tempAccount, &tempState)
+		default:
+			err = errors.Errorf("program %s must not use the participant account", programID)
+		}
+		if err != nil {
+			return errors.Wrapf(ErrInvalidTx, "instruction %d: %v", i, err)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Wrapf(ErrInvalidTx, "missing %d Perun instructions of %s", len(missing), expected.Operation)
+	}
+	if tempState == wrappedSOLAccountCreated {
+		return errors.Wrap(ErrInvalidTx, "temporary wrapped SOL account is not closed")
+	}
+	return nil
+}
+
+// CoSignTx validates the given transaction built by a fee payer against the expected operation, see ValidateTx, and
+// adds the signature of the participant. The transaction can be sent once the fee payer signed it as well.
+func (cb *ContractBackend) CoSignTx(ctx context.Context, tx *solana.Transaction, perunAddr solana.PublicKey, expected ExpectedTx) error {
+	if err := cb.ValidateTx(ctx, tx, perunAddr, expected); err != nil {
+		return err
+	}
+	if _, err := tx.PartialSign(cb.signerKey); err != nil {
+		return errors.Wrap(err, "could not co-sign transaction")
+	}
+	return nil
+}
+
+// validatePerunInstruction checks that the given Perun instruction is one of the missing expected instructions of the
+// given operation and removes it from them.
+func validatePerunInstruction(msg *solana.Message, ix solana.CompiledInstruction, op Operation, missing *[]solana.Instruction) error {
+	for i, want := range *missing {
+		equal, err := equalInstruction(msg, ix, want)
+		if err != nil {
+			return err
+		}
+		if equal {
+			*missing = slices.Delete(*missing, i, i+1)
+			return nil
+		}
+	}
+	return errors.Errorf("Perun instruction is not one of the expected instructions of %s", op)
+}
+
+// equalInstruction returns whether the given compiled instruction has the data and the accounts of the given
+// instruction. Accounts that are writable or signers in the given instruction must be so in the message as well.
+func equalInstruction(msg *solana.Message, ix solana.CompiledInstruction, want solana.Instruction) (bool, error) {
+	data, err := want.Data()
+	if err != nil {
+		return false, errors.Wrap(err, "could not encode expected instruction")
+	}
+	accounts := want.Accounts()
+	if !bytes.Equal(ix.Data, data) || len(ix.Accounts) != len(accounts) {
+		return false, nil
+	}
+	for i, meta := range accounts {
+		key, err := accountAt(msg, ix.Accounts[i])
+		if err != nil {
+			return false, err
+		}
+		if !key.Equals(meta.PublicKey) || (meta.IsSigner && !msg.IsSigner(key)) {
+			return false, nil
+		}
+		if meta.IsWritable {
+			if writable, err := msg.IsWritable(key); err != nil || !writable {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// validateCreateTokenAccountInstruction allows creating the associated token accounts of the participant and of the
+// given channel PDA.
+func validateCreateTokenAccountInstruction(msg *solana.Message, ix solana.CompiledInstruction, participant solana.PublicKey, channelPDA solana.PublicKey) error {
+	if len(ix.Data) != 1 || ix.Data[0] != createIdempotentInstructionID || len(ix.Accounts) < 6 { //nolint:gomnd
+		return errors.New("associated token account instruction not allowed")
+	}
+	keys := make([]solana.PublicKey, 6) //nolint:gomnd
+	for i := range keys {
+		key, err := accountAt(msg, ix.Accounts[i])
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+	ata, owner, mint, tokenProgram := keys[1], keys[2], keys[3], keys[5]
+	if !owner.Equals(participant) && !owner.Equals(channelPDA) {
+		return errors.Errorf("creating token account of %s, which is neither the participant nor the channel", owner)
+	}
+	if !tokenProgram.Equals(solana.TokenProgramID) && !tokenProgram.Equals(solana.Token2022ProgramID) {
+		return errors.Errorf("creating token account of token program %s", tokenProgram)
+	}
+	want, err := AssociatedTokenAddress(owner, mint, tokenProgram)
+	if err != nil {
+		return err
+	}
+	if !ata.Equals(want) {
+		return errors.Errorf("creating %s, which is not the associated token account of %s", ata, owner)
+	}
+	return nil
+}
+
+// wrappedSOLAccountState is the state of the temporary wrapped SOL account of the participant within a transaction.
+type wrappedSOLAccountState int
+
+const (
+	wrappedSOLAccountUnused wrappedSOLAccountState = iota
+	wrappedSOLAccountCreated
+	wrappedSOLAccountClosed
+)
+
+// validateSystemInstruction allows creating the given temporary wrapped SOL account of the participant and advancing
+// a nonce.
+func validateSystemInstruction(msg *solana.Message, ix solana.CompiledInstruction, participant, tempAccount solana.PublicKey, tempState *wrappedSOLAccountState) error {
+	if len(ix.Data) < 4 { //nolint:gomnd
+		return errors.New("invalid system instruction")
+	}
+	switch binary.LittleEndian.Uint32(ix.Data) {
+	case system.Instruction_AdvanceNonceAccount:
+		return nil
+	case system.Instruction_CreateAccountWithSeed:
+		var create system.CreateAccountWithSeed
+		if err := bin.NewBinDecoder(ix.Data[4:]).Decode(&create); err != nil || len(ix.Accounts) < 2 {
+			return errors.New("invalid create account instruction")
+		}
+		created, err := accountAt(msg, ix.Accounts[1])
+		if err != nil {
+			return err
+		}
+		if !created.Equals(tempAccount) || !create.Base.Equals(participant) {
+			return errors.Errorf("creating %s, which is not the temporary wrapped SOL account", created)
+		}
+		if !create.Owner.Equals(solana.TokenProgramID) || *create.Space != tokenAccountSize {
+			return errors.New("temporary wrapped SOL account is not a token account")
+		}
+		if *tempState != wrappedSOLAccountUnused {
+			return errors.New("temporary wrapped SOL account created twice")
+		}
+		*tempState = wrappedSOLAccountCreated
+		return nil
+	default:
+		return errors.New("system instruction not allowed")
+	}
+}
+
+// validateTokenInstruction allows initializing the given temporary wrapped SOL account of the participant as wrapped
+// SOL account of the participant and closing it into the participant.
+func validateTokenInstruction(msg *solana.Message, ix solana.CompiledInstruction, participant, tempAccount solana.PublicKey, tempState *wrappedSOLAccountState) error {
+	if len(ix.Data) < 1 || len(ix.Accounts) < 2 { //nolint:gomnd
+		return errors.New("invalid token instruction")
+	}
+	account, err := accountAt(msg, ix.Accounts[0])
+	if err != nil {
+		return err
+	}
+	if !account.Equals(tempAccount) {
+		return errors.New("token instruction not allowed")
+	}
+	second, err := accountAt(msg, ix.Accounts[1])
+	if err != nil {
+		return err
+	}
+	switch ix.Data[0] {
+	case token.Instruction_InitializeAccount3:
+		if len(ix.Data) != 1+solana.PublicKeyLength || !solana.PublicKeyFromBytes(ix.Data[1:]).Equals(participant) {
+			return errors.New("temporary wrapped SOL account not owned by the participant")
+		}
+		if !second.Equals(wrappedSOLMint.Mint) {
+			return errors.Errorf("temporary wrapped SOL account of mint %s", second)
+		}
+		return nil
+	case token.Instruction_CloseAccount:
+		if !second.Equals(participant) {
+			return errors.Errorf("closing temporary wrapped SOL account into %s, which is not the participant", second)
+		}
+		if *tempState != wrappedSOLAccountCreated {
+			return errors.New("closing temporary wrapped SOL account that was not created")
+		}
+		*tempState = wrappedSOLAccountClosed
+		return nil
+	default:
+		return errors.New("token instruction not allowed")
+	}
+}
+
+// usesAccount returns whether the given instruction uses the account with the given index.
+func usesAccount(ix solana.CompiledInstruction, idx int) bool {
+	for _, accIdx := range ix.Accounts {
+		if int(accIdx) == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// usesKey returns whether the given instruction uses the given account.
+func usesKey(msg *solana.Message, ix solana.CompiledInstruction, key solana.PublicKey) bool {
+	for _, accIdx := range ix.Accounts {
+		if account, err := accountAt(msg, accIdx); err == nil && account.Equals(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// accountAt returns the account with the given index in the given message, which can be loaded from a lookup table
+// if the tables of the message are set, see setLookupTables.
+func accountAt(msg *solana.Message, idx uint16) (solana.PublicKey, error) {
+	if int(idx) < len(msg.AccountKeys) {
+		return msg.AccountKeys[idx], nil
+	}
+	keys, err := msg.GetAllKeys()
+	if err != nil || int(idx) >= len(keys) {
+		return solana.PublicKey{}, errors.Errorf("could not resolve account %d", idx)
+	}
+	return keys[idx], nil
+}
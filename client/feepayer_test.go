@@ -0,0 +1,376 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/perun-network/perun-solana-backend/channel"
+	"github.com/perun-network/perun-solana-backend/encoding"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+	pwallet "perun.network/go-perun/wallet"
+)
+
+// feePayerServer is a JSON-RPC server that serves the given address lookup table for every account and the rent
+// exemption of a token account.
+func feePayerServer(t *testing.T, table addresslookuptable.AddressLookupTableState) *httptest.Server {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := table.MarshalWithEncoder(bin.NewBinEncoder(buf)); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		result := "2039280"
+		if req.Method == "getAccountInfo" {
+			result = fmt.Sprintf(`{"context":{"slot":1},"value":{"data":["%s","base64"],"executable":false,"lamports":1,"owner":"%s","rentEpoch":0}}`,
+				base64.StdEncoding.EncodeToString(buf.Bytes()), AddressLookupTableProgramID)
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestValidateTx(t *testing.T) {
+	encoding.EnableExtendedLayout(true)
+	t.Cleanup(func() { encoding.EnableExtendedLayout(false) })
+	perun, feePayer, table := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{9}
+	id := pchannel.ID{3}
+	mints := []MintInfo{wrappedSOLMint}
+	channelPDA, err := ChannelPDA(id, perun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault, err := VaultTokenAccount(channelPDA, wrappedSOLMint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableAddresses := solana.PublicKeySlice{solana.SystemProgramID, channelPDA, vault}
+
+	cluster := LocalnetConfig()
+	cluster.RPCURL = feePayerServer(t, addresslookuptable.AddressLookupTableState{
+		TypeIndex:        1,
+		DeactivationSlot: math.MaxUint64,
+		Addresses:        tableAddresses,
+	}).URL
+	cb, err := NewContractBackendWithCluster(*NewRandomConfig(rand.New(rand.NewSource(1))), channel.BackendID, cluster) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.SetWrapSOL(true)
+	participant := cb.signer.participant.SolanaAddress
+
+	must := func(ix solana.Instruction, err error) solana.Instruction {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("could not build instruction: %v", err)
+		}
+		return ix
+	}
+	createVault := must(cb.NewCreateVaultInstruction(channelPDA, wrappedSOLMint))
+	wrap, err := cb.NewWrapSOLInstructions(context.Background(), id, 1_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapOther, err := cb.NewWrapSOLInstructions(context.Background(), pchannel.ID{4}, 1_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrap := must(cb.NewUnwrapSOLInstruction(id))
+	fund := func(chanID pchannel.ID, partyIdx bool, amounts []uint64) solana.Instruction {
+		return must(cb.NewFundInstruction(perun, chanID, partyIdx, amounts, mints))
+	}
+	fundWrapped := func(amounts []uint64) []solana.Instruction {
+		return append(append([]solana.Instruction{createVault}, wrap...), fund(id, true, amounts), unwrap)
+	}
+	temp, _, err := TemporaryWrappedSOLAccount(participant, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ata, err := AssociatedTokenAddress(participant, wrappedSOLMint.Mint, wrappedSOLMint.TokenProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withdrawTo := func(partyIdx bool, receivers ...solana.PublicKey) solana.Instruction {
+		return must(cb.NewWithdrawInstruction(perun, id, partyIdx, false, receivers, mints))
+	}
+	withdraw := withdrawTo(true, temp)
+
+	state := func(version uint64, balances ...int64) *pchannel.State {
+		alloc := pchannel.NewAllocation(2, []pwallet.BackendID{channel.BackendID}, channel.NewSOLSolanaCrossAsset())
+		alloc.SetAssetBalances(alloc.Assets[0], []pchannel.Bal{big.NewInt(balances[0]), big.NewInt(balances[1])})
+		return &pchannel.State{ID: id, Version: version, App: pchannel.NoApp(), Allocation: *alloc, Data: pchannel.NoData()}
+	}
+	sigs := []pwallet.Sig{make(pwallet.Sig, 65), make(pwallet.Sig, 65)}
+	expectedDispute, err := cb.ExpectedDisputeTx(perun, state(2, 1, 2), sigs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedClose, err := cb.ExpectedCloseTx(perun, state(2, 1, 2), sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedProgress, err := cb.ExpectedProgressTx(perun, solana.PublicKey{10}, state(2, 1, 2), true, sigs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFund := ExpectedTx{
+		Operation:    OperationFund,
+		ChannelID:    id,
+		Instructions: []solana.Instruction{fund(id, true, []uint64{1_000})},
+	}
+	expectedWithdraw := ExpectedTx{Operation: OperationWithdraw, ChannelID: id, Instructions: []solana.Instruction{withdraw}}
+
+	tests := []struct {
+		name         string
+		payer        solana.PublicKey
+		instructions []solana.Instruction
+		expected     ExpectedTx
+		lookupTable  bool // Whether the transaction loads accounts from the lookup table.
+		valid        bool
+	}{
+		{
+			name:         "fund wrapped SOL",
+			instructions: fundWrapped([]uint64{1_000}),
+			expected:     expectedFund,
+			valid:        true,
+		},
+		{
+			name:         "fund wrapped SOL with lookup table",
+			instructions: fundWrapped([]uint64{1_000}),
+			expected:     expectedFund,
+			lookupTable:  true,
+			valid:        true,
+		},
+		{
+			name:         "fund with other amounts",
+			instructions: fundWrapped([]uint64{1_001}),
+			expected:     expectedFund,
+		},
+		{
+			name:         "fund without amounts",
+			instructions: fundWrapped(nil),
+			expected:     expectedFund,
+		},
+		{
+			name:         "temporary account not closed",
+			instructions: append(append([]solana.Instruction{createVault}, wrap...), fund(id, true, []uint64{1_000})),
+			expected:     expectedFund,
+		},
+		{
+			name: "temporary account created twice",
+			instructions: append(append(append([]solana.Instruction{createVault}, wrap...), wrap[0]),
+				fund(id, true, []uint64{1_000}), unwrap),
+			expected: expectedFund,
+		},
+		{
+			name:         "temporary account of other channel",
+			instructions: append(append([]solana.Instruction{createVault}, wrapOther...), fund(id, true, []uint64{1_000})),
+			expected:     expectedFund,
+		},
+		{
+			name: "temporary account initialized for other owner",
+			instructions: []solana.Instruction{
+				wrap[0],
+				must(token.NewInitializeAccount3Instruction(solana.PublicKey{5}, temp, wrappedSOLMint.Mint).ValidateAndBuild()),
+				fund(id, true, []uint64{1_000}),
+				unwrap,
+			},
+			expected: expectedFund,
+		},
+		{
+			name: "temporary account closed into other account",
+			instructions: append(append([]solana.Instruction{}, wrap...),
+				fund(id, true, []uint64{1_000}),
+				must(token.NewCloseAccountInstruction(temp, solana.PublicKey{5}, participant, nil).ValidateAndBuild()),
+			),
+			expected: expectedFund,
+		},
+		{
+			name: "associated token account closed",
+			instructions: append(fundWrapped([]uint64{1_000}),
+				must(token.NewCloseAccountInstruction(ata, participant, participant, nil).ValidateAndBuild())),
+			expected: expectedFund,
+		},
+		{
+			name:         "fund other party",
+			instructions: []solana.Instruction{fund(id, false, []uint64{1_000})},
+			expected:     expectedFund,
+		},
+		{
+			name:         "fund other channel",
+			instructions: []solana.Instruction{fund(pchannel.ID{4}, true, []uint64{1_000})},
+			expected:     expectedFund,
+		},
+		{
+			name:         "fund twice",
+			instructions: []solana.Instruction{fund(id, true, []uint64{1_000}), fund(id, true, []uint64{1_000})},
+			expected:     expectedFund,
+		},
+		{
+			name:         "other operation",
+			instructions: []solana.Instruction{withdraw},
+			expected:     expectedFund,
+		},
+		{
+			name:         "missing Perun instruction",
+			instructions: wrap,
+			expected:     expectedFund,
+		},
+		{
+			name:         "participant pays fees",
+			payer:        participant,
+			instructions: []solana.Instruction{fund(id, true, []uint64{1_000})},
+			expected:     expectedFund,
+		},
+		{
+			name:         "withdraw and unwrap",
+			instructions: append(append([]solana.Instruction{}, wrap...), withdraw, unwrap),
+			expected:     expectedWithdraw,
+			valid:        true,
+		},
+		{
+			name:         "withdraw and unwrap with lookup table",
+			instructions: append(append([]solana.Instruction{}, wrap...), withdraw, unwrap),
+			expected:     expectedWithdraw,
+			lookupTable:  true,
+			valid:        true,
+		},
+		{
+			name:         "withdraw for other party",
+			instructions: []solana.Instruction{withdrawTo(false, temp)},
+			expected:     expectedWithdraw,
+		},
+		{
+			name:         "withdraw to other receiver",
+			instructions: []solana.Instruction{withdrawTo(true, solana.PublicKey{5})},
+			expected:     expectedWithdraw,
+		},
+		{
+			name:         "create token account of other owner",
+			instructions: []solana.Instruction{must(cb.NewCreateTokenAccountInstruction(solana.PublicKey{5}, wrappedSOLMint)), withdraw},
+			expected:     expectedWithdraw,
+		},
+		{
+			name:         "transfer to other account",
+			instructions: []solana.Instruction{must(system.NewTransferInstruction(1, participant, solana.PublicKey{6}).ValidateAndBuild()), withdraw},
+			expected:     expectedWithdraw,
+		},
+		{
+			name: "other program uses participant",
+			instructions: []solana.Instruction{
+				solana.NewInstruction(solana.PublicKey{7}, solana.AccountMetaSlice{solana.Meta(participant).WRITE()}, nil),
+				withdraw,
+			},
+			expected: expectedWithdraw,
+		},
+		{
+			name:         "dispute",
+			instructions: []solana.Instruction{must(cb.NewDisputeInstruction(perun, state(2, 1, 2), sigs, nil))},
+			expected:     expectedDispute,
+			valid:        true,
+		},
+		{
+			name:         "dispute with other version",
+			instructions: []solana.Instruction{must(cb.NewDisputeInstruction(perun, state(1, 1, 2), sigs, nil))},
+			expected:     expectedDispute,
+		},
+		{
+			name:         "dispute with other balances",
+			instructions: []solana.Instruction{must(cb.NewDisputeInstruction(perun, state(2, 2, 1), sigs, nil))},
+			expected:     expectedDispute,
+		},
+		{
+			name:         "close",
+			instructions: []solana.Instruction{must(cb.NewCloseInstruction(perun, state(2, 1, 2), sigs))},
+			expected:     expectedClose,
+			valid:        true,
+		},
+		{
+			name:         "close with other state",
+			instructions: []solana.Instruction{must(cb.NewCloseInstruction(perun, state(3, 1, 2), sigs))},
+			expected:     expectedClose,
+		},
+		{
+			name: "progress",
+			instructions: []solana.Instruction{
+				must(cb.NewProgressInstruction(perun, solana.PublicKey{10}, state(2, 1, 2), true, sigs[1])),
+			},
+			expected: expectedProgress,
+			valid:    true,
+		},
+		{
+			name: "progress to other version",
+			instructions: []solana.Instruction{
+				must(cb.NewProgressInstruction(perun, solana.PublicKey{10}, state(3, 1, 2), true, sigs[1])),
+			},
+			expected: expectedProgress,
+		},
+		{
+			name: "progress of other app",
+			instructions: []solana.Instruction{
+				must(cb.NewProgressInstruction(perun, solana.PublicKey{11}, state(2, 1, 2), true, sigs[1])),
+			},
+			expected: expectedProgress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payer := feePayer
+			if !tt.payer.IsZero() {
+				payer = tt.payer
+			}
+			opts := []solana.TransactionOption{solana.TransactionPayer(payer)}
+			if tt.lookupTable {
+				tables := map[solana.PublicKey]solana.PublicKeySlice{table: tableAddresses}
+				opts = append(opts, solana.TransactionAddressTables(tables))
+			}
+			tx, err := solana.NewTransaction(tt.instructions, solana.Hash{8}, opts...)
+			if err != nil {
+				t.Fatalf("could not build transaction: %v", err)
+			}
+			if tt.lookupTable {
+				// Decode the transaction as received from the fee payer, without the address tables.
+				data, err := tx.MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tx, err = solana.TransactionFromBytes(data); err != nil {
+					t.Fatal(err)
+				}
+				if tx.Message.AddressTableLookups.NumLookups() == 0 {
+					t.Fatal("transaction does not load accounts from the lookup table")
+				}
+			}
+			err = cb.ValidateTx(context.Background(), tx, perun, tt.expected)
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidTx) {
+				t.Fatalf("got error %v, want %v", err, ErrInvalidTx)
+			}
+		})
+	}
+}
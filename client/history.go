@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/perun-network/perun-solana-backend/encoding"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+)
+
+// signaturesPageSize is the number of signatures requested per getSignaturesForAddress call.
+const signaturesPageSize = 1000
+
+// ChannelEventType is the type of an event in the on-chain history of a channel.
+type ChannelEventType int
+
+const (
+	ChannelOpened ChannelEventType = iota
+	ChannelFundedA
+	ChannelFundedB
+	ChannelDisputed
+	ChannelClosed
+	ChannelForceClosed
+	ChannelWithdrawn
+	ChannelAborted
+	ChannelProgressed
+)
+
+// String returns the name of the event type.
+func (t ChannelEventType) String() string {
+	switch t {
+	case ChannelOpened:
+		return "opened"
+	case ChannelFundedA:
+		return "funded A"
+	case ChannelFundedB:
+		return "funded B"
+	case ChannelDisputed:
+		return "disputed"
+	case ChannelClosed:
+		return "closed"
+	case ChannelForceClosed:
+		return "force closed"
+	case ChannelWithdrawn:
+		return "withdrawn"
+	case ChannelAborted:
+		return "aborted"
+	case ChannelProgressed:
+		return "progressed"
+	default:
+		return "unknown"
+	}
+}
+
+// ChannelEvent is an event in the on-chain history of a channel, reconstructed from a Perun program instruction.
+type ChannelEvent struct {
+	Type      ChannelEventType
+	Signature solana.Signature // The signature of the transaction containing the instruction.
+	Slot      uint64
+	BlockTime time.Time // The zero time if the block time is not available.
+
+	Params        *encoding.Params       // Set for ChannelOpened.
+	State         *encoding.ChannelState // Set for ChannelOpened, ChannelDisputed, ChannelClosed and ChannelProgressed.
+	PartyIdx      bool                   // The withdrawing party for ChannelWithdrawn, the actor for ChannelProgressed.
+	OneWithdrawer bool                   // Whether both parties were paid out for ChannelWithdrawn.
+}
+
+// Version returns the version of the state carried by the event, or zero if it carries no state.
+func (e ChannelEvent) Version() uint64 {
+	if e.State == nil {
+		return 0
+	}
+	return e.State.Version
+}
+
+// GetChannelHistory reconstructs the history of the channel with the given ID from the successful transactions that
+// touched its channel PDA. The events are returned in the order they were executed on-chain.
+func (cb *ContractBackend) GetChannelHistory(ctx context.Context, perunAddr solana.PublicKey, chanID pchannel.ID) ([]ChannelEvent, error) {
+	channelPDA, err := ChannelPDA(chanID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetChannelHistory: could not get channel PDA")
+	}
+	sigs, err := cb.getSignatures(ctx, channelPDA)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetChannelHistory")
+	}
+
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetChannelHistory")
+	}
+	maxVersion := uint64(0)
+	var events []ChannelEvent
+	// Signatures are returned newest first.
+	for i := len(sigs) - 1; i >= 0; i-- {
+		sig := sigs[i]
+		if sig.Err != nil {
+			continue // Failed transactions did not change the channel.
+		}
+		txResult, err := rpcClient.GetTransaction(ctx, sig.Signature, &rpc.GetTransactionOpts{
+			Encoding:                       solana.EncodingBase64,
+			Commitment:                     cb.historyCommitment(),
+			MaxSupportedTransactionVersion: &maxVersion,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetChannelHistory: could not get transaction %s", sig.Signature)
+		}
+		txEvents, err := channelEventsFromTx(txResult, perunAddr, chanID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetChannelHistory: could not decode transaction %s", sig.Signature)
+		}
+		for j := range txEvents {
+			txEvents[j].Signature = sig.Signature
+		}
+		events = append(events, txEvents...)
+	}
+	return events, nil
+}
+
+// historyCommitment returns the commitment of history reads, which cannot use the processed commitment.
+func (cb *ContractBackend) historyCommitment() rpc.CommitmentType {
+	if cb.cluster.Commitments.Read == rpc.CommitmentProcessed {
+		return rpc.CommitmentConfirmed
+	}
+	return cb.cluster.Commitments.Read
+}
+
+// getSignatures returns the signatures of all transactions that touched the given account, newest first.
+func (cb *ContractBackend) getSignatures(ctx context.Context, account solana.PublicKey) ([]*rpc.TransactionSignature, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	limit := signaturesPageSize
+	var sigs []*rpc.TransactionSignature
+	var before solana.Signature
+	for {
+		page, err := rpcClient.GetSignaturesForAddressWithOpts(ctx, account, &rpc.GetSignaturesForAddressOpts{
+			Limit:      &limit,
+			Before:     before,
+			Commitment: cb.historyCommitment(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get signatures for address")
+		}
+		sigs = append(sigs, page...)
+		if len(page) < limit {
+			return sigs, nil
+		}
+		before = page[len(page)-1].Signature
+	}
+}
+
+// channelEventsFromTx decodes the Perun program instructions of the given transaction that concern the given
+// channel.
+func channelEventsFromTx(txResult *rpc.GetTransactionResult, perunAddr solana.PublicKey, chanID pchannel.ID) ([]ChannelEvent, error) {
+	if txResult == nil || txResult.Transaction == nil {
+		return nil, errors.New("transaction not found")
+	}
+	tx, err := txResult.Transaction.GetTransaction()
+	if err != nil {
+		return nil, err
+	}
+
+	var blockTime time.Time
+	if txResult.BlockTime != nil {
+		blockTime = txResult.BlockTime.Time()
+	}
+	var events []ChannelEvent
+	for _, ix := range tx.Message.Instructions {
+		programID, err := tx.ResolveProgramIDIndex(ix.ProgramIDIndex)
+		if err != nil {
+			return nil, err
+		}
+		if !programID.Equals(perunAddr) {
+			continue
+		}
+		instr, err := encoding.DecodePerunInstruction(ix.Data)
+		if err != nil {
+			return nil, err
+		}
+		event, ok := channelEventFromInstruction(instr, chanID)
+		if !ok {
+			continue
+		}
+		event.Slot = txResult.Slot
+		event.BlockTime = blockTime
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// channelEventFromInstruction converts the given instruction to a channel event. It reports false if the
+// instruction does not concern the given channel.
+func channelEventFromInstruction(instr encoding.PerunInstruction, chanID pchannel.ID) (ChannelEvent, bool) {
+	switch instr.Enum {
+	case 0:
+		return ChannelEvent{Type: ChannelOpened, Params: &instr.Open.Params, State: &instr.Open.State},
+			instr.Open.State.ChannelID == chanID
+	case 1:
+		eventType := ChannelFundedA
+		if instr.Fund.PartyIdx {
+			eventType = ChannelFundedB
+		}
+		return ChannelEvent{Type: eventType}, instr.Fund.ChannelID == chanID
+	case 2: //nolint:gomnd
+		return ChannelEvent{Type: ChannelClosed, State: &instr.Close.State}, instr.Close.State.ChannelID == chanID
+	case 3: //nolint:gomnd
+		return ChannelEvent{Type: ChannelForceClosed}, instr.ForceClose.ChannelID == chanID
+	case 4: //nolint:gomnd
+		return ChannelEvent{Type: ChannelDisputed, State: &instr.Dispute.State}, instr.Dispute.State.ChannelID == chanID
+	case 5: //nolint:gomnd
+		return ChannelEvent{
+			Type:          ChannelWithdrawn,
+			PartyIdx:      instr.Withdraw.PartyIdx,
+			OneWithdrawer: instr.Withdraw.OneWithdrawer,
+		}, instr.Withdraw.ChannelID == chanID
+	case 6: //nolint:gomnd
+		return ChannelEvent{Type: ChannelAborted}, instr.AbortFunding.ChannelID == chanID
+	case 7: //nolint:gomnd
+		return ChannelEvent{
+			Type:     ChannelProgressed,
+			State:    &instr.Progress.State,
+			PartyIdx: instr.Progress.ActorIdx,
+		}, instr.Progress.State.ChannelID == chanID
+	default:
+		return ChannelEvent{}, false
+	}
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/perun-network/perun-solana-backend/encoding"
+	pchannel "perun.network/go-perun/channel"
+)
+
+func encodeInstruction(t *testing.T, instr encoding.PerunInstruction) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := bin.NewBorshEncoder(buf).Encode(instr); err != nil {
+		t.Fatalf("could not encode instruction: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestChannelEventFromInstruction(t *testing.T) {
+	id, other := pchannel.ID{1}, pchannel.ID{2}
+	state := encoding.ChannelState{ChannelID: id, Version: 3}
+	params := encoding.Params{ChallengeDuration: 4, LedgerChannel: true}
+
+	tests := []struct {
+		name  string
+		instr encoding.PerunInstruction
+		want  ChannelEvent
+	}{
+		{
+			name:  "open",
+			instr: encoding.PerunInstruction{Enum: 0, Open: encoding.OpenInstruction{Params: params, State: state}},
+			want:  ChannelEvent{Type: ChannelOpened, Params: &params, State: &state},
+		},
+		{
+			name:  "fund A",
+			instr: encoding.PerunInstruction{Enum: 1, Fund: encoding.FundInstruction{ChannelID: id}},
+			want:  ChannelEvent{Type: ChannelFundedA},
+		},
+		{
+			name:  "fund B",
+			instr: encoding.PerunInstruction{Enum: 1, Fund: encoding.FundInstruction{ChannelID: id, PartyIdx: true}},
+			want:  ChannelEvent{Type: ChannelFundedB},
+		},
+		{
+			name:  "close",
+			instr: encoding.PerunInstruction{Enum: 2, Close: encoding.CloseInstruction{State: state}},
+			want:  ChannelEvent{Type: ChannelClosed, State: &state},
+		},
+		{
+			name:  "force close",
+			instr: encoding.PerunInstruction{Enum: 3, ForceClose: encoding.ForceCloseInstruction{ChannelID: id}},
+			want:  ChannelEvent{Type: ChannelForceClosed},
+		},
+		{
+			name:  "dispute",
+			instr: encoding.PerunInstruction{Enum: 4, Dispute: encoding.DisputeInstruction{State: state}},
+			want:  ChannelEvent{Type: ChannelDisputed, State: &state},
+		},
+		{
+			name: "withdraw",
+			instr: encoding.PerunInstruction{Enum: 5, Withdraw: encoding.WithdrawInstruction{
+				ChannelID:     id,
+				PartyIdx:      true,
+				OneWithdrawer: true,
+			}},
+			want: ChannelEvent{Type: ChannelWithdrawn, PartyIdx: true, OneWithdrawer: true},
+		},
+		{
+			name:  "abort funding",
+			instr: encoding.PerunInstruction{Enum: 6, AbortFunding: encoding.AbortFundingInstruction{ChannelID: id}},
+			want:  ChannelEvent{Type: ChannelAborted},
+		},
+		{
+			name:  "progress",
+			instr: encoding.PerunInstruction{Enum: 7, Progress: encoding.ProgressInstruction{State: state, ActorIdx: true}},
+			want:  ChannelEvent{Type: ChannelProgressed, State: &state, PartyIdx: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := channelEventFromInstruction(tt.instr, id)
+			if !ok {
+				t.Fatal("instruction does not concern the channel")
+			}
+			if got.Type != tt.want.Type || got.PartyIdx != tt.want.PartyIdx || got.OneWithdrawer != tt.want.OneWithdrawer {
+				t.Fatalf("got event %+v, want %+v", got, tt.want)
+			}
+			if (got.Params == nil) != (tt.want.Params == nil) || got.Params != nil && *got.Params != *tt.want.Params {
+				t.Fatalf("got params %+v, want %+v", got.Params, tt.want.Params)
+			}
+			if got.Version() != tt.want.Version() || (got.State == nil) != (tt.want.State == nil) {
+				t.Fatalf("got state %+v, want %+v", got.State, tt.want.State)
+			}
+
+			if _, ok := channelEventFromInstruction(tt.instr, other); ok {
+				t.Fatal("instruction concerns another channel")
+			}
+		})
+	}
+
+	if _, ok := channelEventFromInstruction(encoding.PerunInstruction{Enum: 8}, id); ok {
+		t.Fatal("unknown instruction concerns the channel")
+	}
+}
+
+func TestChannelEventsFromTx(t *testing.T) {
+	payer, perun := solana.PublicKey{1}, solana.PublicKey{2}
+	id := pchannel.ID{3}
+	perunInstruction := func(instr encoding.PerunInstruction) solana.Instruction {
+		return solana.NewInstruction(perun, solana.AccountMetaSlice{solana.Meta(payer).WRITE().SIGNER()}, encodeInstruction(t, instr))
+	}
+	txResult := func(blockTime string, instructions ...solana.Instruction) *rpc.GetTransactionResult {
+		tx, err := solana.NewTransaction(instructions, solana.Hash{4}, solana.TransactionPayer(payer))
+		if err != nil {
+			t.Fatalf("could not build transaction: %v", err)
+		}
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("could not encode transaction: %v", err)
+		}
+		raw := fmt.Sprintf(`{"slot":5,"blockTime":%s,"transaction":["%s","base64"]}`, blockTime, base64.StdEncoding.EncodeToString(data))
+		var result rpc.GetTransactionResult
+		if err := json.Unmarshal([]byte(raw), &result); err != nil {
+			t.Fatalf("could not decode transaction result: %v", err)
+		}
+		return &result
+	}
+
+	result := txResult("6",
+		solana.NewInstruction(solana.ComputeBudget, nil, []byte{2, 0, 0, 0, 0}),
+		perunInstruction(encoding.PerunInstruction{Enum: 1, Fund: encoding.FundInstruction{ChannelID: pchannel.ID{7}}}),
+		perunInstruction(encoding.PerunInstruction{Enum: 1, Fund: encoding.FundInstruction{ChannelID: id}}),
+		perunInstruction(encoding.PerunInstruction{Enum: 1, Fund: encoding.FundInstruction{ChannelID: id, PartyIdx: true}}),
+	)
+	events, err := channelEventsFromTx(result, perun, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != ChannelFundedA || events[1].Type != ChannelFundedB {
+		t.Fatalf("got events %+v, want funded A and funded B", events)
+	}
+	for _, event := range events {
+		if event.Slot != 5 || !event.BlockTime.Equal(time.Unix(6, 0)) {
+			t.Fatalf("got slot %d and block time %v, want 5 and %v", event.Slot, event.BlockTime, time.Unix(6, 0))
+		}
+	}
+
+	events, err = channelEventsFromTx(txResult("null", perunInstruction(encoding.PerunInstruction{
+		Enum:       3,
+		ForceClose: encoding.ForceCloseInstruction{ChannelID: id},
+	})), perun, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != ChannelForceClosed || !events[0].BlockTime.IsZero() {
+		t.Fatalf("got events %+v, want force closed without block time", events)
+	}
+
+	if _, err := channelEventsFromTx(txResult("6", solana.NewInstruction(perun, nil, []byte{0xff})), perun, id); err == nil {
+		t.Fatal("expected error for undecodable instruction")
+	}
+	if _, err := channelEventsFromTx(&rpc.GetTransactionResult{}, perun, id); err == nil {
+		t.Fatal("expected error for missing transaction")
+	}
+}
@@ -2,10 +2,12 @@ package client
 
 import (
 	"github.com/gagliardetto/solana-go"
+	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
 	system "github.com/gagliardetto/solana-go/programs/system"
 	"github.com/perun-network/perun-solana-backend/encoding"
 	"github.com/pkg/errors"
 	pchannel "perun.network/go-perun/channel"
+	pwallet "perun.network/go-perun/wallet"
 )
 
 // ChannelPDA computes the Program Derived Address (PDA) for a Perun channel on Solana.
@@ -20,6 +22,82 @@ func ChannelPDA(channelID [32]byte, perunAddr solana.PublicKey) (solana.PublicKe
 	return pda, nil
 }
 
+// VaultTokenAccount computes the associated token account of the channel PDA for the given mint, which holds the SPL
+// tokens of the channel.
+func VaultTokenAccount(channelPDA solana.PublicKey, mint MintInfo) (solana.PublicKey, error) {
+	vault, err := AssociatedTokenAddress(channelPDA, mint.Mint, mint.TokenProgram)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "could not find vault token account")
+	}
+	return vault, nil
+}
+
+// createIdempotentInstructionID is the instruction index of CreateIdempotent in the associated token account program,
+// which is not covered by the solana-go bindings.
+const createIdempotentInstructionID = 1
+
+// NewCreateVaultInstruction creates an instruction that creates the vault token account of the channel PDA for the
+// given mint, paid by the participant. It succeeds without changes if the vault already exists.
+func (cb *ContractBackend) NewCreateVaultInstruction(channelPDA solana.PublicKey, mint MintInfo) (solana.Instruction, error) {
+	vault, err := VaultTokenAccount(channelPDA, mint)
+	if err != nil {
+		return nil, err
+	}
+	return cb.newCreateIdempotentInstruction(vault, channelPDA, mint), nil
+}
+
+// newCreateIdempotentInstruction creates an instruction that creates the given associated token account of the given
+// owner, paid by the participant.
+func (cb *ContractBackend) newCreateIdempotentInstruction(ata solana.PublicKey, owner solana.PublicKey, mint MintInfo) solana.Instruction {
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account paying the rent
+		solana.NewAccountMeta(ata, true, false),                                // Token account to create
+		solana.NewAccountMeta(owner, false, false),                             // Owner of the token account
+		solana.NewAccountMeta(mint.Mint, false, false),                         // Mint of the token
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+		solana.NewAccountMeta(mint.TokenProgram, false, false),                 // Token program owning the mint
+	}
+	return solana.NewInstruction(
+		associatedtokenaccount.ProgramID,      // Program ID
+		accounts,                              // Accounts to be passed to the instruction
+		[]byte{createIdempotentInstructionID}, // Instruction data
+	)
+}
+
+// tokenAccountMetas returns the account metas needed to move the given SPL tokens of the given channel between the
+// channel vault and the given owners. For every mint, these are the token program owning the mint, the mint, the vault
+// token account and the token account of each owner. The token account is the associated token account of the owner,
+// except for wrapped SOL of the participant if SOL is wrapped, which is moved through its temporary wrapped SOL
+// account.
+func (cb *ContractBackend) tokenAccountMetas(channelPDA solana.PublicKey, chanID pchannel.ID, mints []MintInfo, owners []solana.PublicKey) ([]*solana.AccountMeta, error) {
+	participant := cb.signer.participant.SolanaAddress
+	var accounts []*solana.AccountMeta
+	for _, mint := range mints {
+		vault, err := VaultTokenAccount(channelPDA, mint)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts,
+			solana.NewAccountMeta(mint.TokenProgram, false, false), // Token program owning the mint
+			solana.NewAccountMeta(mint.Mint, false, false),         // Mint of the token
+			solana.NewAccountMeta(vault, true, false),              // Vault token account of the channel
+		)
+		for _, owner := range owners {
+			var tokenAccount solana.PublicKey
+			if cb.WrapSOL() && mint.Mint.Equals(wrappedSOLMint.Mint) && owner.Equals(participant) {
+				tokenAccount, _, err = TemporaryWrappedSOLAccount(owner, chanID)
+			} else {
+				tokenAccount, err = AssociatedTokenAddress(owner, mint.Mint, mint.TokenProgram)
+			}
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, solana.NewAccountMeta(tokenAccount, true, false)) // Owner's token account
+		}
+	}
+	return accounts, nil
+}
+
 // NewOpenInstruction creates a new Open instruction for the Perun channel.
 func (cb *ContractBackend) NewOpenInstruction(perunAddr solana.PublicKey, params *pchannel.Params, state *pchannel.State) (solana.Instruction, error) {
 	perunID := perunAddr // Perun program address, should be set to the actual Perun program address on Solana
@@ -50,8 +128,12 @@ func (cb *ContractBackend) NewOpenInstruction(perunAddr solana.PublicKey, params
 	return openIx, nil
 }
 
-func (cb *ContractBackend) NewFundInstruction(perunAddr solana.PublicKey, chanID pchannel.ID, funderIdx bool) (solana.Instruction, error) {
-	data, err := encoding.MakeFundInstruction(chanID, funderIdx)
+// NewFundInstruction creates a new Fund instruction depositing the balance of the participant into the Perun channel.
+// The amounts are transferred per channel token and include transfer fees, nil amounts transfer the balances. For each
+// of the given SPL mints, the token program, the mint, the vault token account and the participant's token account are
+// passed along.
+func (cb *ContractBackend) NewFundInstruction(perunAddr solana.PublicKey, chanID pchannel.ID, funderIdx bool, amounts []uint64, mints []MintInfo) (solana.Instruction, error) {
+	data, err := encoding.MakeFundInstruction(chanID, funderIdx, amounts)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create open instruction")
 	}
@@ -67,6 +149,11 @@ func (cb *ContractBackend) NewFundInstruction(perunAddr solana.PublicKey, chanID
 		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
 		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
 	}
+	tokenAccounts, err := cb.tokenAccountMetas(channelPDA, chanID, mints, []solana.PublicKey{cb.signer.participant.SolanaAddress})
+	if err != nil {
+		return nil, err
+	}
+	accounts = append(accounts, tokenAccounts...)
 	fundIx := solana.NewInstruction(
 		perunAddr, // Program ID
 		accounts,  // Accounts to be passed to the instruction
@@ -74,3 +161,195 @@ func (cb *ContractBackend) NewFundInstruction(perunAddr solana.PublicKey, chanID
 	)
 	return fundIx, nil
 }
+
+// NewDisputeInstruction creates a new Dispute instruction registering the given state for the Perun channel together
+// with the states of its sub-channels.
+func (cb *ContractBackend) NewDisputeInstruction(perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig, subChannels []pchannel.SignedState) (solana.Instruction, error) {
+	data, err := encoding.MakeDisputeInstruction(state, sigs, subChannels)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create dispute instruction")
+	}
+	var channelID [32]byte
+	copy(channelID[:], state.ID[:])
+	channelPDA, err := ChannelPDA(channelID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel PDA")
+	}
+
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(channelPDA, true, false),                         // Program account derived from channel ID
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+	}
+	for _, sub := range subChannels {
+		subPDA, err := ChannelPDA(sub.State.ID, perunAddr)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get sub-channel PDA")
+		}
+		accounts = append(accounts, solana.NewAccountMeta(subPDA, true, false)) // Program account of the sub-channel
+	}
+	disputeIx := solana.NewInstruction(
+		perunAddr, // Program ID
+		accounts,  // Accounts to be passed to the instruction
+		data,      // Instruction data
+	)
+	return disputeIx, nil
+}
+
+// NewCloseInstruction creates a new Close instruction concluding the Perun channel with the given final state.
+func (cb *ContractBackend) NewCloseInstruction(perunAddr solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig) (solana.Instruction, error) {
+	data, err := encoding.MakeCloseInstruction(state, sigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create close instruction")
+	}
+	var channelID [32]byte
+	copy(channelID[:], state.ID[:])
+	channelPDA, err := ChannelPDA(channelID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel PDA")
+	}
+
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(channelPDA, true, false),                         // Program account derived from channel ID
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+	}
+	closeIx := solana.NewInstruction(
+		perunAddr, // Program ID
+		accounts,  // Accounts to be passed to the instruction
+		data,      // Instruction data
+	)
+	return closeIx, nil
+}
+
+// NewForceCloseInstruction creates a new ForceClose instruction concluding a disputed Perun channel. The locked funds
+// are settled according to the registered states of the given sub-channels.
+func (cb *ContractBackend) NewForceCloseInstruction(perunAddr solana.PublicKey, chanID pchannel.ID, subChannels []pchannel.ID) (solana.Instruction, error) {
+	subIDs := make([][32]byte, len(subChannels))
+	for i, id := range subChannels {
+		subIDs[i] = id
+	}
+	data, err := encoding.MakeForceCloseInstruction(chanID, subIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create force close instruction")
+	}
+	var channelID [32]byte
+	copy(channelID[:], chanID[:])
+	channelPDA, err := ChannelPDA(channelID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel PDA")
+	}
+
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(channelPDA, true, false),                         // Program account derived from channel ID
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+	}
+	for _, subID := range subIDs {
+		subPDA, err := ChannelPDA(subID, perunAddr)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get sub-channel PDA")
+		}
+		accounts = append(accounts, solana.NewAccountMeta(subPDA, true, false)) // Program account of the sub-channel
+	}
+	forceCloseIx := solana.NewInstruction(
+		perunAddr, // Program ID
+		accounts,  // Accounts to be passed to the instruction
+		data,      // Instruction data
+	)
+	return forceCloseIx, nil
+}
+
+// NewWithdrawInstruction creates a new Withdraw instruction paying out the given receivers of a closed Perun channel.
+// For each of the given SPL mints, the token program, the mint, the vault token account and the receivers' token
+// accounts are passed along, see tokenAccountMetas.
+func (cb *ContractBackend) NewWithdrawInstruction(perunAddr solana.PublicKey, chanID pchannel.ID, partyIdx bool, oneWithdrawer bool, receivers []solana.PublicKey, mints []MintInfo) (solana.Instruction, error) {
+	data, err := encoding.MakeWithdrawInstruction(chanID, partyIdx, oneWithdrawer)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create withdraw instruction")
+	}
+	var channelID [32]byte
+	copy(channelID[:], chanID[:])
+	channelPDA, err := ChannelPDA(channelID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel PDA")
+	}
+
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(channelPDA, true, false),                         // Program account derived from channel ID
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+	}
+	for _, receiver := range receivers {
+		accounts = append(accounts, solana.NewAccountMeta(receiver, true, false)) // Receiver's account
+	}
+	tokenAccounts, err := cb.tokenAccountMetas(channelPDA, chanID, mints, receivers)
+	if err != nil {
+		return nil, err
+	}
+	accounts = append(accounts, tokenAccounts...)
+	withdrawIx := solana.NewInstruction(
+		perunAddr, // Program ID
+		accounts,  // Accounts to be passed to the instruction
+		data,      // Instruction data
+	)
+	return withdrawIx, nil
+}
+
+// NewAbortFundingInstruction creates a new AbortFunding instruction aborting the funding of the Perun channel and
+// refunding the given receivers.
+func (cb *ContractBackend) NewAbortFundingInstruction(perunAddr solana.PublicKey, chanID pchannel.ID, receivers []solana.PublicKey) (solana.Instruction, error) {
+	data, err := encoding.MakeAbortFundingInstruction(chanID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create abort funding instruction")
+	}
+	var channelID [32]byte
+	copy(channelID[:], chanID[:])
+	channelPDA, err := ChannelPDA(channelID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel PDA")
+	}
+
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(channelPDA, true, false),                         // Program account derived from channel ID
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+	}
+	for _, receiver := range receivers {
+		accounts = append(accounts, solana.NewAccountMeta(receiver, true, false)) // Receiver's account
+	}
+	abortIx := solana.NewInstruction(
+		perunAddr, // Program ID
+		accounts,  // Accounts to be passed to the instruction
+		data,      // Instruction data
+	)
+	return abortIx, nil
+}
+
+// NewProgressInstruction creates a new Progress instruction progressing a disputed Perun app channel to the given
+// state. The app program is passed along so that the Perun program can validate the transition.
+func (cb *ContractBackend) NewProgressInstruction(perunAddr solana.PublicKey, appProgram solana.PublicKey, state *pchannel.State, actorIdx bool, sig pwallet.Sig) (solana.Instruction, error) {
+	data, err := encoding.MakeProgressInstruction(state, actorIdx, sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create progress instruction")
+	}
+	var channelID [32]byte
+	copy(channelID[:], state.ID[:])
+	channelPDA, err := ChannelPDA(channelID, perunAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get channel PDA")
+	}
+
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(channelPDA, true, false),                         // Program account derived from channel ID
+		solana.NewAccountMeta(cb.signer.participant.SolanaAddress, true, true), // Participant's account
+		solana.NewAccountMeta(system.ProgramID, false, false),                  // System program account
+		solana.NewAccountMeta(appProgram, false, false),                        // App program validating the transition
+	}
+	progressIx := solana.NewInstruction(
+		perunAddr, // Program ID
+		accounts,  // Accounts to be passed to the instruction
+		data,      // Instruction data
+	)
+	return progressIx, nil
+}
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/perun-network/perun-solana-backend/encoding"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+)
+
+const (
+	// MaxTxSize is the maximum size of a serialized transaction, which is bounded by the packet size of the cluster.
+	MaxTxSize = 1232
+
+	lookupTableCreateInstrID = 0
+	lookupTableExtendInstrID = 2
+	maxExtendAddresses       = 20 // Addresses per extend transaction, so that it stays below MaxTxSize.
+)
+
+// AddressLookupTableProgramID is the program ID of the address lookup table program.
+var AddressLookupTableProgramID = solana.MustPublicKeyFromBase58("AddressLookupTab1e1111111111111111111111111")
+
+// ErrTxTooLarge is returned for transactions that exceed MaxTxSize when serialized.
+var ErrTxTooLarge = errors.New("transaction too large")
+
+// AddLookupTables adds the given address lookup tables to the tables used for transactions. If any tables are set,
+// transactions are built as v0 transactions that load the accounts contained in the tables from them.
+func (cb *ContractBackend) AddLookupTables(tables ...solana.PublicKey) {
+	cb.lookupMtx.Lock()
+	defer cb.lookupMtx.Unlock()
+	for _, table := range tables {
+		cb.lookupTables.UniqueAppend(table)
+	}
+}
+
+// RemoveLookupTable removes the given address lookup table from the tables used for transactions.
+func (cb *ContractBackend) RemoveLookupTable(table solana.PublicKey) {
+	cb.lookupMtx.Lock()
+	defer cb.lookupMtx.Unlock()
+	for i, t := range cb.lookupTables {
+		if t.Equals(table) {
+			cb.lookupTables = append(cb.lookupTables[:i], cb.lookupTables[i+1:]...)
+			return
+		}
+	}
+}
+
+// CreateLookupTable creates an address lookup table with the signer as authority that contains the given addresses
+// and returns its address.
+func (cb *ContractBackend) CreateLookupTable(ctx context.Context, addresses solana.PublicKeySlice) (solana.PublicKey, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateLookupTable")
+	}
+	slot, err := rpcClient.GetSlot(ctx, cb.cluster.Commitments.Blockhash)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateLookupTable: could not get slot")
+	}
+	authority := cb.signer.privateKey.PublicKey()
+	slotBytes := make([]byte, 8) //nolint:gomnd
+	binary.LittleEndian.PutUint64(slotBytes, slot)
+	table, bump, err := solana.FindProgramAddress([][]byte{authority[:], slotBytes}, AddressLookupTableProgramID)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateLookupTable: could not find lookup table address")
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, lookupTableCreateInstrID)
+	data = binary.LittleEndian.AppendUint64(data, slot)
+	data = append(data, bump)
+	createIx := cb.newLookupTableInstruction(table, data)
+	if _, err := cb.submitInstructions(ctx, []solana.Instruction{createIx}); err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateLookupTable")
+	}
+	if err := cb.ExtendLookupTable(ctx, table, addresses); err != nil {
+		return solana.PublicKey{}, err
+	}
+	return table, nil
+}
+
+// ExtendLookupTable adds the given addresses that the given address lookup table does not contain yet to it. The
+// signer has to be the authority of the table.
+func (cb *ContractBackend) ExtendLookupTable(ctx context.Context, table solana.PublicKey, addresses solana.PublicKeySlice) error {
+	state, err := cb.GetLookupTable(ctx, table)
+	if err != nil {
+		return errors.Wrap(err, "ExtendLookupTable")
+	}
+	var missing solana.PublicKeySlice
+	for _, address := range addresses {
+		if !state.Addresses.Contains(address) {
+			missing.UniqueAppend(address)
+		}
+	}
+	if len(state.Addresses)+len(missing) > addresslookuptable.LOOKUP_TABLE_MAX_ADDRESSES {
+		return errors.Errorf("ExtendLookupTable: lookup table %s cannot hold %d more addresses", table, len(missing))
+	}
+
+	for len(missing) > 0 {
+		chunk := missing[:min(len(missing), maxExtendAddresses)]
+		missing = missing[len(chunk):]
+
+		data := binary.LittleEndian.AppendUint32(nil, lookupTableExtendInstrID)
+		data = binary.LittleEndian.AppendUint64(data, uint64(len(chunk)))
+		for _, address := range chunk {
+			data = append(data, address[:]...)
+		}
+		extendIx := cb.newLookupTableInstruction(table, data)
+		if _, err := cb.submitInstructions(ctx, []solana.Instruction{extendIx}); err != nil {
+			return errors.Wrap(err, "ExtendLookupTable")
+		}
+	}
+	return nil
+}
+
+// newLookupTableInstruction creates an instruction of the address lookup table program with the given data that
+// modifies the given table with the signer as authority and payer.
+func (cb *ContractBackend) newLookupTableInstruction(table solana.PublicKey, data []byte) solana.Instruction {
+	authority := cb.signer.privateKey.PublicKey()
+	accounts := []*solana.AccountMeta{
+		solana.NewAccountMeta(table, true, false),                   // Lookup table account
+		solana.NewAccountMeta(authority, false, true),               // Authority of the lookup table
+		solana.NewAccountMeta(authority, true, true),                // Signer's account paying the rent
+		solana.NewAccountMeta(solana.SystemProgramID, false, false), // System program account
+	}
+	return solana.NewInstruction(AddressLookupTableProgramID, accounts, data)
+}
+
+// GetLookupTable fetches and decodes the given address lookup table.
+func (cb *ContractBackend) GetLookupTable(ctx context.Context, table solana.PublicKey) (*addresslookuptable.AddressLookupTableState, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetLookupTable")
+	}
+	accountInfo, err := rpcClient.GetAccountInfoWithOpts(ctx, table, &rpc.GetAccountInfoOpts{
+		Commitment: cb.cluster.Commitments.Read,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get lookup table %s", table)
+	}
+	if accountInfo == nil || accountInfo.Value == nil {
+		return nil, errors.Errorf("lookup table %s not found", table)
+	}
+	if !accountInfo.Value.Owner.Equals(AddressLookupTableProgramID) {
+		return nil, errors.Errorf("account %s is not an address lookup table", table)
+	}
+	var state addresslookuptable.AddressLookupTableState
+	if err := state.UnmarshalWithDecoder(bin.NewBinDecoder(accountInfo.Value.Data.GetBinary())); err != nil {
+		return nil, errors.Wrapf(ErrCouldNotDecodeTx, "could not decode lookup table %s: %v", table, err)
+	}
+	return &state, nil
+}
+
+// ChannelLookupAddresses returns the addresses of the channel with the given state that are worth storing in an
+// address lookup table: the channel PDA, and the mint, token program and vault of each SPL token of the channel.
+func (cb *ContractBackend) ChannelLookupAddresses(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State) (solana.PublicKeySlice, error) {
+	balances, err := encoding.MakeBalances(state.Allocation)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode balances")
+	}
+	channelPDA, err := ChannelPDA(state.ID, perunAddr)
+	if err != nil {
+		return nil, err
+	}
+	mints, err := cb.splMints(ctx, balances.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transaction builder never loads the first address of a table, so it holds the system program, which is
+	// mostly invoked and therefore not loaded anyway.
+	addresses := solana.PublicKeySlice{solana.SystemProgramID, channelPDA}
+	for _, mint := range mints {
+		vault, err := VaultTokenAccount(channelPDA, mint)
+		if err != nil {
+			return nil, err
+		}
+		addresses.UniqueAppend(mint.Mint)
+		addresses.UniqueAppend(mint.TokenProgram)
+		addresses.UniqueAppend(vault)
+	}
+	return addresses, nil
+}
+
+// CreateChannelLookupTable creates an address lookup table holding the addresses of the channel with the given state,
+// see ChannelLookupAddresses, and adds it to the tables used for transactions.
+func (cb *ContractBackend) CreateChannelLookupTable(ctx context.Context, perunAddr solana.PublicKey, state *pchannel.State) (solana.PublicKey, error) {
+	addresses, err := cb.ChannelLookupAddresses(ctx, perunAddr, state)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateChannelLookupTable")
+	}
+	table, err := cb.CreateLookupTable(ctx, addresses)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	cb.AddLookupTables(table)
+	return table, nil
+}
+
+// transactionOptions returns the options for building a transaction paid by the fee payer. If address lookup tables
+// are set, the transaction is built as v0 transaction using them.
+func (cb *ContractBackend) transactionOptions(ctx context.Context) ([]solana.TransactionOption, error) {
+	opts := []solana.TransactionOption{solana.TransactionPayer(cb.FeePayer())}
+
+	cb.lookupMtx.Lock()
+	tables := append(solana.PublicKeySlice(nil), cb.lookupTables...)
+	cb.lookupMtx.Unlock()
+	if len(tables) == 0 {
+		return opts, nil
+	}
+
+	addressTables := make(map[solana.PublicKey]solana.PublicKeySlice, len(tables))
+	for _, table := range tables {
+		state, err := cb.GetLookupTable(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		if !state.IsActive() {
+			continue // Deactivated tables can no longer be used.
+		}
+		addressTables[table] = state.Addresses
+	}
+	return append(opts, solana.TransactionAddressTables(addressTables)), nil
+}
+
+// setLookupTables fetches the address lookup tables of the given v0 message and sets them to resolve the accounts it
+// loads from them, unless they are set already.
+func (cb *ContractBackend) setLookupTables(ctx context.Context, msg *solana.Message) error {
+	if !msg.IsVersioned() || msg.AddressTableLookups.NumLookups() == 0 || msg.GetAddressTables() != nil {
+		return nil
+	}
+	addressTables := make(map[solana.PublicKey]solana.PublicKeySlice, msg.AddressTableLookups.NumLookups())
+	for _, lookup := range msg.AddressTableLookups {
+		state, err := cb.GetLookupTable(ctx, lookup.AccountKey)
+		if err != nil {
+			return err
+		}
+		addressTables[lookup.AccountKey] = state.Addresses
+	}
+	return msg.SetAddressTables(addressTables)
+}
+
+// checkTxSize returns ErrTxTooLarge if the given signed transaction exceeds MaxTxSize.
+func checkTxSize(tx *solana.Transaction) error {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "could not encode transaction")
+	}
+	if len(data) > MaxTxSize {
+		return errors.Wrapf(ErrTxTooLarge, "%d bytes exceed the limit of %d bytes, consider using address lookup tables", len(data), MaxTxSize)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	addresslookuptable "github.com/gagliardetto/solana-go/programs/address-lookup-table"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/pkg/errors"
+)
+
+func TestLookupTables(t *testing.T) {
+	config := NewRandomConfig(rand.New(rand.NewSource(1))) //nolint:gosec
+	payer := config.privateKey.PublicKey()
+	receiver := solana.PublicKey{9}
+	tableState := func(deactivationSlot uint64) *addresslookuptable.AddressLookupTableState {
+		return &addresslookuptable.AddressLookupTableState{
+			TypeIndex:        1,
+			DeactivationSlot: deactivationSlot,
+			Addresses:        solana.PublicKeySlice{solana.SystemProgramID, receiver},
+		}
+	}
+	active, deactivated, notTable, missing := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}, solana.PublicKey{4}
+	cb := newAccountBackend(t, config, map[solana.PublicKey]testAccount{
+		active:      {owner: AddressLookupTableProgramID, data: tableState(math.MaxUint64)},
+		deactivated: {owner: AddressLookupTableProgramID, data: tableState(1)},
+		notTable:    {owner: solana.SystemProgramID, data: tableState(math.MaxUint64)},
+	})
+	transfer, err := system.NewTransferInstruction(1, payer, receiver).ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		tables      []solana.PublicKey
+		wantLookups int // The number of tables the transaction loads accounts from.
+		wantErr     bool
+	}{
+		{name: "no tables"},
+		{name: "active table", tables: []solana.PublicKey{active}, wantLookups: 1},
+		{name: "deactivated table", tables: []solana.PublicKey{deactivated}},
+		{name: "active and deactivated table", tables: []solana.PublicKey{deactivated, active}, wantLookups: 1},
+		{name: "not a lookup table", tables: []solana.PublicKey{notTable}, wantErr: true},
+		{name: "missing table", tables: []solana.PublicKey{missing}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb.AddLookupTables(tt.tables...)
+			defer func() {
+				for _, table := range tt.tables {
+					cb.RemoveLookupTable(table)
+				}
+			}()
+
+			opts, err := cb.transactionOptions(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tx, err := solana.NewTransaction([]solana.Instruction{transfer}, solana.Hash{5}, opts...)
+			if err != nil {
+				t.Fatalf("could not build transaction: %v", err)
+			}
+			if got := tx.Message.AddressTableLookups.NumLookups(); got != tt.wantLookups {
+				t.Fatalf("transaction loads accounts from %d tables, want %d", got, tt.wantLookups)
+			}
+			if !tx.Message.AccountKeys[0].Equals(payer) {
+				t.Fatalf("got fee payer %s, want %s", tx.Message.AccountKeys[0], payer)
+			}
+			if tt.wantLookups == 0 {
+				return
+			}
+
+			// The receiver received from the fee payer is resolved by fetching the table.
+			data, err := tx.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			received, err := solana.TransactionFromBytes(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cb.setLookupTables(context.Background(), &received.Message); err != nil {
+				t.Fatalf("could not set lookup tables: %v", err)
+			}
+			keys, err := received.Message.GetAllKeys()
+			if err != nil {
+				t.Fatalf("could not resolve accounts: %v", err)
+			}
+			if !keys.Contains(receiver) {
+				t.Fatalf("resolved accounts %v do not contain the receiver %s", keys, receiver)
+			}
+		})
+	}
+}
+
+func TestSetLookupTables(t *testing.T) {
+	config := NewRandomConfig(rand.New(rand.NewSource(1))) //nolint:gosec
+	payer := config.privateKey.PublicKey()
+	cb := newAccountBackend(t, config, nil)
+	transfer, err := system.NewTransferInstruction(1, payer, solana.PublicKey{9}).ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[solana.PublicKey]solana.PublicKeySlice{{1}: {solana.SystemProgramID, {9}}}
+
+	legacy, err := solana.NewTransaction([]solana.Instruction{transfer}, solana.Hash{5}, solana.TransactionPayer(payer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.setLookupTables(context.Background(), &legacy.Message); err != nil {
+		t.Fatalf("unexpected error for legacy transaction: %v", err)
+	}
+
+	resolved, err := solana.NewTransaction([]solana.Instruction{transfer}, solana.Hash{5}, solana.TransactionPayer(payer),
+		solana.TransactionAddressTables(tables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.setLookupTables(context.Background(), &resolved.Message); err != nil {
+		t.Fatalf("unexpected error for transaction with set tables: %v", err)
+	}
+
+	data, err := resolved.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unresolved, err := solana.TransactionFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.setLookupTables(context.Background(), &unresolved.Message); err == nil {
+		t.Fatal("got no error for transaction loading accounts from a missing table")
+	}
+}
+
+func TestCheckTxSize(t *testing.T) {
+	payer := solana.PublicKey{1}
+	tests := []struct {
+		name      string
+		transfers int // The number of transfers to distinct receivers.
+		wantErr   bool
+	}{
+		{name: "single transfer", transfers: 1},
+		{name: "many transfers", transfers: 15},
+		{name: "too many transfers", transfers: 30, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instructions := make([]solana.Instruction, tt.transfers)
+			for i := range instructions {
+				transfer, err := system.NewTransferInstruction(1, payer, solana.PublicKey{2, byte(i)}).ValidateAndBuild()
+				if err != nil {
+					t.Fatal(err)
+				}
+				instructions[i] = transfer
+			}
+			tx, err := solana.NewTransaction(instructions, solana.Hash{3}, solana.TransactionPayer(payer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx.Signatures = make([]solana.Signature, tx.Message.Header.NumRequiredSignatures)
+
+			err = checkTxSize(tx)
+			if tt.wantErr && !errors.Is(err, ErrTxTooLarge) {
+				t.Fatalf("got error %v, want %v", err, ErrTxTooLarge)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
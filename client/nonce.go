@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"time"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+	pwallet "perun.network/go-perun/wallet"
+)
+
+const (
+	nonceAccountSize       = 80 // Size of a nonce account of the system program.
+	nonceStateInitialized  = 1
+	nonceAdvanceInstrIndex = 0 // Durable nonce transactions must advance the nonce in their first instruction.
+)
+
+// ErrNonceAdvanced is returned if a durable nonce transaction can no longer land because the nonce of its nonce
+// account was advanced by another transaction.
+var ErrNonceAdvanced = errors.New("nonce advanced")
+
+// CreateNonceAccount creates a nonce account with the given authority, funded with its rent exemption by the signer,
+// and returns its address. The authority has to sign the transactions using the nonce account.
+func (cb *ContractBackend) CreateNonceAccount(ctx context.Context, authority solana.PublicKey) (solana.PublicKey, error) {
+	nonceKey, err := solana.NewRandomPrivateKey()
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateNonceAccount: could not generate nonce account key")
+	}
+	nonceAccount := nonceKey.PublicKey()
+
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateNonceAccount")
+	}
+	rent, err := rpcClient.GetMinimumBalanceForRentExemption(ctx, nonceAccountSize, cb.cluster.Commitments.Read)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateNonceAccount: could not get rent exemption")
+	}
+	createIx, err := system.NewCreateAccountInstruction(
+		rent,
+		nonceAccountSize,
+		solana.SystemProgramID,
+		cb.signer.privateKey.PublicKey(),
+		nonceAccount,
+	).ValidateAndBuild()
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateNonceAccount: could not create account instruction")
+	}
+	initIx, err := system.NewInitializeNonceAccountInstruction(
+		authority,
+		nonceAccount,
+		solana.SysVarRecentBlockHashesPubkey,
+		solana.SysVarRentPubkey,
+	).ValidateAndBuild()
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateNonceAccount: could not create initialize nonce instruction")
+	}
+
+	if _, err := cb.submitInstructions(ctx, []solana.Instruction{createIx, initIx}, nonceKey); err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "CreateNonceAccount")
+	}
+	return nonceAccount, nil
+}
+
+// GetNonceAccount fetches and decodes the given initialized nonce account.
+func (cb *ContractBackend) GetNonceAccount(ctx context.Context, nonceAccount solana.PublicKey) (system.NonceAccount, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return system.NonceAccount{}, errors.Wrap(err, "GetNonceAccount")
+	}
+	accountInfo, err := rpcClient.GetAccountInfoWithOpts(ctx, nonceAccount, &rpc.GetAccountInfoOpts{
+		Commitment: cb.cluster.Commitments.Read,
+	})
+	if err != nil {
+		return system.NonceAccount{}, errors.Wrapf(err, "could not get nonce account %s", nonceAccount)
+	}
+	if accountInfo == nil || accountInfo.Value == nil {
+		return system.NonceAccount{}, errors.Errorf("nonce account %s not found", nonceAccount)
+	}
+	if !accountInfo.Value.Owner.Equals(solana.SystemProgramID) {
+		return system.NonceAccount{}, errors.Errorf("account %s is not owned by the system program", nonceAccount)
+	}
+
+	var nonce system.NonceAccount
+	if err := bin.NewBinDecoder(accountInfo.Value.Data.GetBinary()).Decode(&nonce); err != nil {
+		return system.NonceAccount{}, errors.Wrapf(ErrCouldNotDecodeTx, "could not decode nonce account %s: %v", nonceAccount, err)
+	}
+	if nonce.State != nonceStateInitialized {
+		return system.NonceAccount{}, errors.Errorf("nonce account %s is not initialized", nonceAccount)
+	}
+	return nonce, nil
+}
+
+// GetNonce returns the current nonce of the given nonce account, which durable nonce transactions use as blockhash.
+func (cb *ContractBackend) GetNonce(ctx context.Context, nonceAccount solana.PublicKey) (solana.Hash, error) {
+	nonce, err := cb.GetNonceAccount(ctx, nonceAccount)
+	if err != nil {
+		return solana.Hash{}, err
+	}
+	return solana.Hash(nonce.Nonce), nil
+}
+
+// NewNonceTx builds a durable nonce transaction of the given operation consisting of the given instructions and signs
+// it. The transaction advances the nonce of the given nonce account, whose authority has to be the signer, and uses
+// the current nonce as blockhash. It therefore does not expire until the nonce is advanced and can be broadcast with
+// SendNonceTx at any later time. The fees are paid by the fee payer of the backend.
+func (cb *ContractBackend) NewNonceTx(ctx context.Context, nonceAccount solana.PublicKey, op Operation, instructions ...solana.Instruction) (*solana.Transaction, error) {
+	nonce, err := cb.GetNonceAccount(ctx, nonceAccount)
+	if err != nil {
+		return nil, err
+	}
+	authority := cb.signer.privateKey.PublicKey()
+	if !nonce.AuthorizedPubkey.Equals(authority) {
+		return nil, errors.Errorf("signer is not the authority %s of nonce account %s", nonce.AuthorizedPubkey, nonceAccount)
+	}
+
+	advanceIx, err := system.NewAdvanceNonceAccountInstruction(
+		nonceAccount,
+		solana.SysVarRecentBlockHashesPubkey,
+		authority,
+	).ValidateAndBuild()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create advance nonce instruction")
+	}
+	budgetIxs, err := cb.computeBudgetInstructions(ctx, op, instructions)
+	if err != nil {
+		return nil, err
+	}
+	txIxs := append([]solana.Instruction{advanceIx}, budgetIxs...)
+	txIxs = append(txIxs, instructions...)
+
+	txOpts, err := cb.transactionOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := solana.NewTransaction(txIxs, solana.Hash(nonce.Nonce), txOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create transaction")
+	}
+	if _, err := tx.Sign(cb.signerKeys(nil)); err != nil {
+		return nil, errors.Wrap(err, "could not sign transaction")
+	}
+	if err := checkTxSize(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// SendNonceTx broadcasts the given durable nonce transaction built by NewNonceTx until it is confirmed, at most for the
+// confirm timeout of the cluster config. It returns ErrNonceAdvanced if the nonce was advanced by another transaction,
+// so that the transaction can no longer land.
+func (cb *ContractBackend) SendNonceTx(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	nonceAccount, err := nonceAccountOf(tx)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	if cb.preflight() {
+		if _, err := cb.SimulateTx(ctx, tx); err != nil {
+			return solana.Signature{}, errors.Wrap(err, "SendNonceTx: preflight failed")
+		}
+	}
+
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return solana.Signature{}, errors.Wrap(err, "SendNonceTx")
+	}
+	ctx, cancel := cb.confirmContext(ctx)
+	defer cancel()
+	sig := tx.Signatures[0]
+	maxRetries := uint(0)
+	opts := rpc.TransactionOpts{SkipPreflight: true, MaxRetries: &maxRetries}
+	state := signatureUnknown
+	for {
+		if state == signatureUnknown {
+			if _, err := rpcClient.SendTransactionWithOpts(ctx, tx, opts); err != nil {
+				log.Printf("Could not broadcast transaction %s: %v", sig, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return sig, errors.Wrapf(ctx.Err(), "transaction %s not confirmed", sig)
+		case <-time.After(time.Duration(cb.cluster.Timeouts.RebroadcastInterval)):
+		}
+
+		state, err = cb.signatureState(ctx, tx, false)
+		if errors.Is(err, ErrTxFailed) {
+			return sig, err
+		}
+		if err != nil {
+			log.Printf("Could not get status of transaction %s: %v", sig, err)
+			continue
+		}
+		if state == signatureConfirmed {
+			return sig, nil
+		}
+		if state == signaturePending {
+			continue
+		}
+
+		// The transaction advances the nonce when it lands, so a different nonce means that either the transaction
+		// landed in the meantime or another transaction used the nonce.
+		nonce, err := cb.GetNonce(ctx, nonceAccount)
+		if err != nil {
+			log.Printf("Could not get nonce of %s: %v", nonceAccount, err)
+			continue
+		}
+		if nonce == tx.Message.RecentBlockhash {
+			continue
+		}
+		state, err = cb.signatureState(ctx, tx, true)
+		if err != nil || state != signatureUnknown {
+			if errors.Is(err, ErrTxFailed) || state == signatureConfirmed {
+				return sig, err
+			}
+			continue
+		}
+		return sig, errors.Wrapf(ErrNonceAdvanced, "nonce account %s", nonceAccount)
+	}
+}
+
+// PrepareDisputeTx builds and signs a durable nonce transaction disputing the channel with the given state and
+// signatures, see Dispute and NewNonceTx. The transaction can be kept ready and broadcast with SendNonceTx at any time
+// during the challenge duration.
+func (cb *ContractBackend) PrepareDisputeTx(ctx context.Context, perunAddr solana.PublicKey, nonceAccount solana.PublicKey, state *pchannel.State, sigs []pwallet.Sig, subChannels []pchannel.SignedState) (*solana.Transaction, error) {
+	disputeIx, err := cb.NewDisputeInstruction(perunAddr, state, sigs, subChannels)
+	if err != nil {
+		return nil, errors.Wrap(err, "PrepareDisputeTx: could not create dispute instruction")
+	}
+	tx, err := cb.NewNonceTx(ctx, nonceAccount, OperationDispute, disputeIx)
+	if err != nil {
+		return nil, errors.Wrap(err, "PrepareDisputeTx")
+	}
+	return tx, nil
+}
+
+// nonceAccountOf returns the nonce account advanced by the first instruction of the given durable nonce transaction.
+func nonceAccountOf(tx *solana.Transaction) (solana.PublicKey, error) {
+	if len(tx.Message.Instructions) == 0 {
+		return solana.PublicKey{}, errors.New("transaction has no instructions")
+	}
+	ix := tx.Message.Instructions[nonceAdvanceInstrIndex]
+	programID, err := tx.Message.Program(ix.ProgramIDIndex)
+	isAdvance := len(ix.Data) >= 4 && binary.LittleEndian.Uint32(ix.Data) == system.Instruction_AdvanceNonceAccount //nolint:gomnd
+	if err != nil || !programID.Equals(solana.SystemProgramID) || !isAdvance || len(ix.Accounts) == 0 {
+		return solana.PublicKey{}, errors.New("transaction does not advance a nonce in its first instruction")
+	}
+	accounts, err := ix.ResolveInstructionAccounts(&tx.Message)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "could not resolve nonce account")
+	}
+	return accounts[0].PublicKey, nil
+}
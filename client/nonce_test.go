@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/perun-network/perun-solana-backend/channel"
+)
+
+// testAccount is an account served by accountInfoServer.
+type testAccount struct {
+	owner solana.PublicKey
+	data  interface{} // Encoded with the bin encoder.
+}
+
+// accountInfoServer is a JSON-RPC server that serves the given accounts and missing accounts for all other keys.
+func accountInfoServer(t *testing.T, accounts map[solana.PublicKey]testAccount) *httptest.Server {
+	t.Helper()
+	encoded := make(map[solana.PublicKey]string, len(accounts))
+	for key, account := range accounts {
+		buf := new(bytes.Buffer)
+		if err := bin.NewBinEncoder(buf).Encode(account.data); err != nil {
+			t.Fatal(err)
+		}
+		encoded[key] = fmt.Sprintf(`{"data":["%s","base64"],"executable":false,"lamports":1,"owner":"%s","rentEpoch":0}`,
+			base64.StdEncoding.EncodeToString(buf.Bytes()), account.owner)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []json.RawMessage
+		}
+		var key solana.PublicKey
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Method != "getAccountInfo" || len(req.Params) == 0 || json.Unmarshal(req.Params[0], &key) != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		value, ok := encoded[key]
+		if !ok {
+			value = "null"
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"context":{"slot":1},"value":%s}}`, req.ID, value)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newAccountBackend returns a contract backend with the given signer connected to a server serving the given accounts.
+func newAccountBackend(t *testing.T, config *SignerConfig, accounts map[solana.PublicKey]testAccount) *ContractBackend {
+	t.Helper()
+	cluster := LocalnetConfig()
+	cluster.RPCURL = accountInfoServer(t, accounts).URL
+	cb, err := NewContractBackendWithCluster(*config, channel.BackendID, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cb
+}
+
+func TestNewNonceTx(t *testing.T) {
+	config := NewRandomConfig(rand.New(rand.NewSource(1))) //nolint:gosec
+	authority := config.privateKey.PublicKey()
+	nonce := solana.PublicKey{9}
+	nonceAccount := func(state uint32, authority solana.PublicKey) *system.NonceAccount {
+		return &system.NonceAccount{State: state, AuthorizedPubkey: authority, Nonce: nonce}
+	}
+	initialized, uninitialized, otherAuthority := solana.PublicKey{1}, solana.PublicKey{2}, solana.PublicKey{3}
+	notSystemOwned, missing := solana.PublicKey{4}, solana.PublicKey{5}
+	cb := newAccountBackend(t, config, map[solana.PublicKey]testAccount{
+		initialized:    {owner: solana.SystemProgramID, data: nonceAccount(nonceStateInitialized, authority)},
+		uninitialized:  {owner: solana.SystemProgramID, data: nonceAccount(0, authority)},
+		otherAuthority: {owner: solana.SystemProgramID, data: nonceAccount(nonceStateInitialized, solana.PublicKey{6})},
+		notSystemOwned: {owner: solana.TokenProgramID, data: nonceAccount(nonceStateInitialized, authority)},
+	})
+	transfer, err := system.NewTransferInstruction(1, authority, solana.PublicKey{7}).ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		nonceAccount solana.PublicKey
+		wantErr      bool
+	}{
+		{name: "initialized", nonceAccount: initialized},
+		{name: "uninitialized", nonceAccount: uninitialized, wantErr: true},
+		{name: "other authority", nonceAccount: otherAuthority, wantErr: true},
+		{name: "not owned by the system program", nonceAccount: notSystemOwned, wantErr: true},
+		{name: "missing", nonceAccount: missing, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := cb.NewNonceTx(context.Background(), tt.nonceAccount, OperationDispute, transfer)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tx.Message.RecentBlockhash != solana.Hash(nonce) {
+				t.Fatalf("got blockhash %s, want nonce %s", tx.Message.RecentBlockhash, nonce)
+			}
+			if len(tx.Message.Instructions) != 2 {
+				t.Fatalf("got %d instructions, want advance nonce and transfer", len(tx.Message.Instructions))
+			}
+			got, err := nonceAccountOf(tx)
+			if err != nil {
+				t.Fatalf("could not get nonce account: %v", err)
+			}
+			if !got.Equals(tt.nonceAccount) {
+				t.Fatalf("got nonce account %s, want %s", got, tt.nonceAccount)
+			}
+			if err := tx.VerifySignatures(); err != nil {
+				t.Fatalf("invalid signatures: %v", err)
+			}
+		})
+	}
+}
+
+func TestNonceAccountOf(t *testing.T) {
+	nonceAccount, authority := solana.PublicKey{1}, solana.PublicKey{2}
+	advance, err := system.NewAdvanceNonceAccountInstruction(nonceAccount, solana.SysVarRecentBlockHashesPubkey, authority).
+		ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer, err := system.NewTransferInstruction(1, authority, solana.PublicKey{3}).ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withdrawData := binary.LittleEndian.AppendUint32(nil, system.Instruction_WithdrawNonceAccount)
+	withdraw := solana.NewInstruction(solana.SystemProgramID, advance.Accounts(), withdrawData)
+	advanceData := binary.LittleEndian.AppendUint32(nil, system.Instruction_AdvanceNonceAccount)
+	otherProgram := solana.NewInstruction(solana.PublicKey{4}, advance.Accounts(), advanceData)
+
+	tests := []struct {
+		name         string
+		instructions []solana.Instruction
+		wantErr      bool
+	}{
+		{name: "advance first", instructions: []solana.Instruction{advance, transfer}},
+		{name: "advance not first", instructions: []solana.Instruction{transfer, advance}, wantErr: true},
+		{name: "other system instruction", instructions: []solana.Instruction{withdraw, transfer}, wantErr: true},
+		{name: "other program", instructions: []solana.Instruction{otherProgram, transfer}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := solana.NewTransaction(tt.instructions, solana.Hash{5}, solana.TransactionPayer(authority))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := nonceAccountOf(tx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got nonce account %s, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equals(nonceAccount) {
+				t.Fatalf("got nonce account %s, want %s", got, nonceAccount)
+			}
+		})
+	}
+
+	if _, err := nonceAccountOf(&solana.Transaction{}); err == nil {
+		t.Fatal("got nonce account of transaction without instructions")
+	}
+}
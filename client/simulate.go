@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+)
+
+// ErrSimulationFailed is returned if a transaction fails the preflight simulation without an instruction error, for
+// example because of an expired blockhash or insufficient funds for the fee.
+var ErrSimulationFailed = errors.New("transaction simulation failed")
+
+// ProgramError is an instruction error of a simulated transaction. The custom error codes of the Perun program are
+// reported as they are, the on-chain state has to be read to find out why an instruction failed.
+type ProgramError struct {
+	InstructionIndex int
+	ProgramID        solana.PublicKey
+	Code             *uint32 // The custom error code, nil for errors of the runtime.
+	Reason           string  // The error reported by the runtime if there is no custom error code.
+}
+
+func (e *ProgramError) Error() string {
+	if e.Code == nil {
+		return fmt.Sprintf("instruction %d of program %s failed: %s", e.InstructionIndex, e.ProgramID, e.Reason)
+	}
+	return fmt.Sprintf("instruction %d of program %s failed with custom error %d", e.InstructionIndex, e.ProgramID, *e.Code)
+}
+
+// SimulationResult is the result of the preflight simulation of a transaction.
+type SimulationResult struct {
+	UnitsConsumed uint64   // The compute units consumed by the simulation.
+	Logs          []string // The program logs of the simulation.
+}
+
+// SimulationError is returned if a transaction fails the preflight simulation. It wraps the decoded cause, which is a
+// *ProgramError for instruction errors, and carries the result of the simulation for debugging.
+type SimulationError struct {
+	SimulationResult
+	Err error
+}
+
+func (e *SimulationError) Error() string {
+	return fmt.Sprintf("simulation failed after %d compute units: %v\n%s", e.UnitsConsumed, e.Err, strings.Join(e.Logs, "\n"))
+}
+
+func (e *SimulationError) Unwrap() error {
+	return e.Err
+}
+
+// SetPreflight sets whether transactions are simulated before they are sent, which is enabled by default.
+func (cb *ContractBackend) SetPreflight(preflight bool) {
+	cb.preflightMtx.Lock()
+	defer cb.preflightMtx.Unlock()
+	cb.skipPreflight = !preflight
+}
+
+// preflight returns whether transactions are simulated before they are sent.
+func (cb *ContractBackend) preflight() bool {
+	cb.preflightMtx.Lock()
+	defer cb.preflightMtx.Unlock()
+	return !cb.skipPreflight
+}
+
+// SimulateTx simulates the given signed transaction. If the simulation fails, the result is returned together with a
+// *SimulationError.
+func (cb *ContractBackend) SimulateTx(ctx context.Context, tx *solana.Transaction) (SimulationResult, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return SimulationResult{}, err
+	}
+	out, err := rpcClient.SimulateTransactionWithOpts(ctx, tx, &rpc.SimulateTransactionOpts{
+		SigVerify:  true,
+		Commitment: cb.cluster.Commitments.Blockhash,
+	})
+	if err != nil {
+		return SimulationResult{}, errors.Wrap(err, "could not simulate transaction")
+	}
+	if out == nil || out.Value == nil {
+		return SimulationResult{}, errors.New("empty simulation result")
+	}
+
+	result := SimulationResult{Logs: out.Value.Logs}
+	if out.Value.UnitsConsumed != nil {
+		result.UnitsConsumed = *out.Value.UnitsConsumed
+	}
+	if out.Value.Err == nil {
+		return result, nil
+	}
+	return result, &SimulationError{
+		SimulationResult: result,
+		Err:              decodeTxError(tx, out.Value.Err),
+	}
+}
+
+// decodeTxError decodes the transaction error returned by the RPC for the given transaction. Instruction errors are
+// encoded as {"InstructionError": [index, "Reason" | {"Custom": code}]}.
+func decodeTxError(tx *solana.Transaction, txErr interface{}) error {
+	raw, err := json.Marshal(txErr)
+	if err != nil {
+		return errors.Wrapf(ErrSimulationFailed, "%v", txErr)
+	}
+	var ixErr struct {
+		InstructionError []json.RawMessage
+	}
+	if err := json.Unmarshal(raw, &ixErr); err != nil || len(ixErr.InstructionError) != 2 { //nolint:gomnd
+		return errors.Wrapf(ErrSimulationFailed, "%s", raw)
+	}
+
+	var progErr ProgramError
+	if err := json.Unmarshal(ixErr.InstructionError[0], &progErr.InstructionIndex); err != nil {
+		return errors.Wrapf(ErrSimulationFailed, "%s", raw)
+	}
+	if progErr.InstructionIndex >= 0 && progErr.InstructionIndex < len(tx.Message.Instructions) {
+		programID, err := tx.Message.Program(tx.Message.Instructions[progErr.InstructionIndex].ProgramIDIndex)
+		if err == nil {
+			progErr.ProgramID = programID
+		}
+	}
+	var custom struct {
+		Custom *uint32
+	}
+	if err := json.Unmarshal(ixErr.InstructionError[1], &custom); err == nil && custom.Custom != nil {
+		progErr.Code = custom.Custom
+	} else {
+		progErr.Reason = strings.Trim(string(ixErr.InstructionError[1]), `"`)
+	}
+	return &progErr
+}
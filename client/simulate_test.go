@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/pkg/errors"
+)
+
+func TestDecodeTxError(t *testing.T) {
+	payer, perun := solana.PublicKey{1}, solana.PublicKey{2}
+	tx, err := solana.NewTransaction(
+		[]solana.Instruction{
+			solana.NewInstruction(solana.ComputeBudget, nil, []byte{0}),
+			solana.NewInstruction(perun, solana.AccountMetaSlice{solana.Meta(payer).WRITE().SIGNER()}, []byte{1}),
+		},
+		solana.Hash{3},
+		solana.TransactionPayer(payer),
+	)
+	if err != nil {
+		t.Fatalf("could not build transaction: %v", err)
+	}
+	code := func(c uint32) *uint32 { return &c }
+
+	tests := []struct {
+		name     string
+		txErr    string
+		want     *ProgramError
+		wantErr  error // The error the decoded error wraps if it is not an instruction error.
+		simError bool  // Whether the error is not an instruction error.
+	}{
+		{
+			name:  "custom error of the perun program",
+			txErr: `{"InstructionError":[1,{"Custom":5}]}`,
+			want:  &ProgramError{InstructionIndex: 1, ProgramID: perun, Code: code(5)},
+		},
+		{
+			name:  "custom error of a builtin program",
+			txErr: `{"InstructionError":[0,{"Custom":5}]}`,
+			want:  &ProgramError{InstructionIndex: 0, ProgramID: solana.ComputeBudget, Code: code(5)},
+		},
+		{
+			name:  "runtime error",
+			txErr: `{"InstructionError":[1,"InvalidAccountData"]}`,
+			want:  &ProgramError{InstructionIndex: 1, ProgramID: perun, Reason: "InvalidAccountData"},
+		},
+		{
+			name:  "instruction index out of range",
+			txErr: `{"InstructionError":[7,{"Custom":5}]}`,
+			want:  &ProgramError{InstructionIndex: 7, Code: code(5)},
+		},
+		{name: "transaction error", txErr: `"BlockhashNotFound"`, wantErr: ErrSimulationFailed, simError: true},
+		{name: "malformed instruction error", txErr: `{"InstructionError":[1]}`, wantErr: ErrSimulationFailed, simError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var txErr interface{}
+			if err := json.Unmarshal([]byte(tt.txErr), &txErr); err != nil {
+				t.Fatalf("invalid test error: %v", err)
+			}
+			err := decodeTxError(tx, txErr)
+
+			var progErr *ProgramError
+			if tt.simError {
+				if errors.As(err, &progErr) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if !errors.As(err, &progErr) {
+				t.Fatalf("got error %v, want *ProgramError", err)
+			}
+			if progErr.InstructionIndex != tt.want.InstructionIndex || progErr.ProgramID != tt.want.ProgramID ||
+				progErr.Reason != tt.want.Reason || (progErr.Code == nil) != (tt.want.Code == nil) ||
+				(progErr.Code != nil && *progErr.Code != *tt.want.Code) {
+				t.Fatalf("got %+v, want %+v", progErr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+
+	"github.com/perun-network/perun-solana-backend/channel"
+	"github.com/perun-network/perun-solana-backend/encoding"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+)
+
+// DecodeState converts an on-chain channel state to a go-perun state. Tokens of this chain become Solana assets of
+// mints owned by the token program or the Token-2022 program. Tokens of other chains become Ethereum assets. The app
+// of an app channel is resolved from go-perun's app registry, so it must be registered to decode its data.
+func (cb *ContractBackend) DecodeState(ctx context.Context, params encoding.Params, state encoding.ChannelState) (*pchannel.State, error) {
+	assets, err := cb.decodeAssets(ctx, state.Balances.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	alloc, err := decodeAllocation(assets, state.Balances)
+	if err != nil {
+		return nil, err
+	}
+
+	app, data := pchannel.NoApp(), pchannel.NoData()
+	if !params.App.IsZero() {
+		app, err = pchannel.Resolve(channel.NewAppID(params.App))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not resolve app")
+		}
+		data = app.NewData()
+		if err := data.UnmarshalBinary(state.AppData); err != nil {
+			return nil, errors.Wrap(err, "could not decode app data")
+		}
+	}
+
+	return &pchannel.State{
+		ID:         state.ChannelID,
+		Version:    state.Version,
+		App:        app,
+		Allocation: *alloc,
+		Data:       data,
+		IsFinal:    state.Finalized,
+	}, nil
+}
+
+// decodeAssets converts the on-chain channel tokens to go-perun assets.
+func (cb *ContractBackend) decodeAssets(ctx context.Context, tokens []encoding.CrossAsset) ([]pchannel.Asset, error) {
+	assets := make([]pchannel.Asset, len(tokens))
+	for i, t := range tokens {
+		if t.Chain != encoding.Chain(cb.chainID) { //nolint:gosec
+			holder := channel.EthAddress(t.EthAddress)
+			asset := channel.MakeEthAsset(new(big.Int).SetUint64(uint64(t.Chain)), &holder)
+			assets[i] = &asset
+			continue
+		}
+		if t.SolanaAddress.IsZero() {
+			assets[i] = channel.NewSOLSolanaCrossAsset()
+			continue
+		}
+		mint, err := cb.GetMintInfo(ctx, t.SolanaAddress)
+		if err != nil {
+			return nil, err
+		}
+		contractID := channel.MakeContractID(strconv.FormatUint(uint64(t.Chain), 10))
+		asset := channel.NewTokenSolanaCrossAsset(&mint.Mint, contractID)
+		assets[i] = &asset
+	}
+	return assets, nil
+}
+
+// decodeAllocation converts the on-chain balances to a go-perun allocation of the given assets.
+func decodeAllocation(assets []pchannel.Asset, balances encoding.Balances) (*pchannel.Allocation, error) {
+	if len(balances.BalA) != len(assets) || len(balances.BalB) != len(assets) {
+		return nil, errors.New("number of balances does not match number of tokens")
+	}
+	bals := make([][]pchannel.Bal, len(assets))
+	for i := range assets {
+		bals[i] = []pchannel.Bal{
+			new(big.Int).SetUint64(balances.BalA[i]),
+			new(big.Int).SetUint64(balances.BalB[i]),
+		}
+	}
+	locked := make([]pchannel.SubAlloc, len(balances.Locked))
+	for i, sub := range balances.Locked {
+		if len(sub.Balances) != len(assets) {
+			return nil, errors.Errorf("number of locked balances of sub-channel %d does not match number of tokens", i)
+		}
+		subBals := make([]pchannel.Bal, len(sub.Balances))
+		for j, bal := range sub.Balances {
+			subBals[j] = new(big.Int).SetUint64(bal)
+		}
+		indexMap := make([]pchannel.Index, len(sub.IndexMap))
+		for j, idx := range sub.IndexMap {
+			indexMap[j] = pchannel.Index(idx)
+		}
+		locked[i] = *pchannel.NewSubAlloc(sub.ID, subBals, indexMap)
+	}
+	return &pchannel.Allocation{
+		Assets:   assets,
+		Balances: bals,
+		Locked:   locked,
+	}, nil
+}
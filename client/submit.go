@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+)
+
+// MaxResigns is the number of times a transaction is re-signed with a fresh blockhash after the previous one expired
+// without the transaction landing.
+const MaxResigns = 3
+
+var (
+	// ErrTxExpired is returned if a transaction did not land before the blockhash of its last re-signing expired.
+	ErrTxExpired = errors.New("transaction expired")
+	// ErrTxFailed is matched by errors returned for transactions that landed but failed on-chain, see TxFailedError.
+	ErrTxFailed = errors.New("transaction failed")
+)
+
+// TxFailedError is returned if a transaction landed but failed on-chain. It wraps the decoded transaction error, which
+// is a *ProgramError for instruction errors.
+type TxFailedError struct {
+	Signature solana.Signature
+	Err       error
+}
+
+func (e *TxFailedError) Error() string {
+	return fmt.Sprintf("transaction %s failed: %v", e.Signature, e.Err)
+}
+
+func (e *TxFailedError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrTxFailed.
+func (e *TxFailedError) Is(target error) bool {
+	return target == ErrTxFailed
+}
+
+// signatureState is the on-chain state of a sent transaction.
+type signatureState int
+
+const (
+	signatureUnknown   signatureState = iota // The cluster does not know the transaction.
+	signaturePending                         // The transaction landed but is not confirmed at the confirm commitment.
+	signatureConfirmed                       // The transaction is confirmed at the confirm commitment.
+)
+
+// submitInstructions wraps the given instructions into a transaction paid by the fee payer, signs it and broadcasts it
+// until it is confirmed. The given extra signers sign the transaction as well. The transaction is rebroadcast at the
+// rebroadcast interval of the cluster config. Once its blockhash expires without the transaction landing, it is
+// re-signed with a fresh blockhash, at most MaxResigns times. Each signed transaction is awaited at most for the
+// confirm timeout of the cluster config.
+func (cb *ContractBackend) submitInstructions(ctx context.Context, instructions []solana.Instruction, extraSigners ...solana.PrivateKey) (solana.Signature, error) {
+	for attempt := 0; attempt <= MaxResigns; attempt++ {
+		tx, lastValidBlockHeight, err := cb.signInstructions(ctx, instructions, extraSigners)
+		if err != nil {
+			return solana.Signature{}, err
+		}
+		if cb.preflight() {
+			if _, err := cb.SimulateTx(ctx, tx); err != nil {
+				return solana.Signature{}, errors.Wrap(err, "preflight failed")
+			}
+		}
+
+		confirmCtx, cancel := cb.confirmContext(ctx)
+		sig, state, err := cb.broadcastUntilExpired(confirmCtx, tx, lastValidBlockHeight)
+		cancel()
+		if err != nil {
+			return sig, err
+		}
+		if state == signatureConfirmed {
+			return sig, nil
+		}
+		log.Printf("Transaction %s expired without landing, re-signing with a fresh blockhash", sig)
+	}
+	return solana.Signature{}, errors.Wrapf(ErrTxExpired, "not landed after %d re-signings", MaxResigns)
+}
+
+// signInstructions wraps the given instructions into a transaction with a fresh blockhash and signs it. It returns the
+// last valid block height of the blockhash.
+func (cb *ContractBackend) signInstructions(ctx context.Context, instructions []solana.Instruction, extraSigners []solana.PrivateKey) (*solana.Transaction, uint64, error) {
+	cb.cbMutex.Lock()
+	defer cb.cbMutex.Unlock()
+
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	recent, err := rpcClient.GetLatestBlockhash(ctx, cb.cluster.Commitments.Blockhash)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not get latest blockhash")
+	}
+	txOpts, err := cb.transactionOptions(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	tx, err := solana.NewTransaction(instructions, recent.Value.Blockhash, txOpts...)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not create transaction")
+	}
+	if _, err := tx.Sign(cb.signerKeys(extraSigners)); err != nil {
+		return nil, 0, errors.Wrap(err, "could not sign transaction")
+	}
+	if err := checkTxSize(tx); err != nil {
+		return nil, 0, err
+	}
+	return tx, recent.Value.LastValidBlockHeight, nil
+}
+
+// confirmContext returns a context that ends after the confirm timeout of the cluster config, if it is set.
+func (cb *ContractBackend) confirmContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := time.Duration(cb.cluster.Timeouts.Confirm); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// broadcastUntilExpired broadcasts the given signed transaction at the rebroadcast interval until it is confirmed or
+// its blockhash with the given last valid block height expired. It returns signatureUnknown only if the transaction
+// can no longer land, so that it is safe to re-sign it.
+func (cb *ContractBackend) broadcastUntilExpired(ctx context.Context, tx *solana.Transaction, lastValidBlockHeight uint64) (solana.Signature, signatureState, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return solana.Signature{}, signatureUnknown, err
+	}
+	sig := tx.Signatures[0]
+	maxRetries := uint(0) // The transaction is rebroadcast here instead of by the RPC node.
+	opts := rpc.TransactionOpts{SkipPreflight: true, MaxRetries: &maxRetries}
+
+	state := signatureUnknown
+	for {
+		if state == signatureUnknown {
+			if _, err := rpcClient.SendTransactionWithOpts(ctx, tx, opts); err != nil {
+				log.Printf("Could not broadcast transaction %s: %v", sig, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return sig, state, errors.Wrapf(ctx.Err(), "transaction %s not confirmed", sig)
+		case <-time.After(time.Duration(cb.cluster.Timeouts.RebroadcastInterval)):
+		}
+
+		var err error
+		state, err = cb.signatureState(ctx, tx, false)
+		if errors.Is(err, ErrTxFailed) {
+			return sig, state, err
+		}
+		if err != nil {
+			log.Printf("Could not get status of transaction %s: %v", sig, err)
+			continue
+		}
+		if state != signatureUnknown {
+			// Pending transactions are not re-signed even if their blockhash expires.
+			if state == signatureConfirmed {
+				return sig, state, nil
+			}
+			continue
+		}
+
+		// Finalized blocks are never rolled back, so once the finalized block height passed the last valid block
+		// height, the transaction can only have landed in a block that is already known to the cluster.
+		height, err := rpcClient.GetBlockHeight(ctx, rpc.CommitmentFinalized)
+		if err != nil {
+			log.Printf("Could not get block height: %v", err)
+			continue
+		}
+		if height > lastValidBlockHeight {
+			state, err = cb.signatureState(ctx, tx, true)
+			if err != nil || state != signatureUnknown {
+				// The transaction landed after all, so it must not be re-signed. Wait for its confirmation.
+				if errors.Is(err, ErrTxFailed) || state == signatureConfirmed {
+					return sig, state, err
+				}
+				continue
+			}
+			return sig, signatureUnknown, nil
+		}
+	}
+}
+
+// signatureState returns the on-chain state of the given sent transaction. If searchHistory is set, the ledger is
+// searched as well and not only the recent status cache. If the transaction failed on-chain, a *TxFailedError is
+// returned.
+func (cb *ContractBackend) signatureState(ctx context.Context, tx *solana.Transaction, searchHistory bool) (signatureState, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return signatureUnknown, err
+	}
+	statuses, err := rpcClient.GetSignatureStatuses(ctx, searchHistory, tx.Signatures[0])
+	if err != nil {
+		return signatureUnknown, errors.Wrap(err, "could not get signature status")
+	}
+	if len(statuses.Value) == 0 || statuses.Value[0] == nil {
+		return signatureUnknown, nil
+	}
+	status := statuses.Value[0]
+	if status.Err != nil {
+		return signaturePending, &TxFailedError{Signature: tx.Signatures[0], Err: decodeTxError(tx, status.Err)}
+	}
+	if confirmationRank(status.ConfirmationStatus) >= commitmentRank(cb.cluster.Commitments.Confirm) {
+		return signatureConfirmed, nil
+	}
+	return signaturePending, nil
+}
+
+// signerKey returns the private key of the signer for the given public key, or nil.
+func (cb *ContractBackend) signerKey(key solana.PublicKey) *solana.PrivateKey {
+	if cb.signer.privateKey.PublicKey() == key {
+		return cb.signer.privateKey
+	}
+	return nil
+}
+
+// signerKeys returns a function returning the private key of the signer, of the fee payer or of the given extra
+// signers for the given public key, or nil.
+func (cb *ContractBackend) signerKeys(extraSigners []solana.PrivateKey) func(solana.PublicKey) *solana.PrivateKey {
+	feePayer := cb.feePayer()
+	return func(key solana.PublicKey) *solana.PrivateKey {
+		for i := range extraSigners {
+			if extraSigners[i].PublicKey() == key {
+				return &extraSigners[i]
+			}
+		}
+		if feePayer != nil && feePayer.PublicKey() == key {
+			return feePayer
+		}
+		return cb.signerKey(key)
+	}
+}
+
+func confirmationRank(status rpc.ConfirmationStatusType) int {
+	switch status {
+	case rpc.ConfirmationStatusProcessed:
+		return 1
+	case rpc.ConfirmationStatusConfirmed:
+		return 2 //nolint:gomnd
+	case rpc.ConfirmationStatusFinalized:
+		return 3 //nolint:gomnd
+	default:
+		return 0
+	}
+}
+
+func commitmentRank(commitment rpc.CommitmentType) int {
+	return confirmationRank(rpc.ConfirmationStatusType(commitment))
+}
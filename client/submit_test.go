@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/perun-network/perun-solana-backend/channel"
+	"github.com/pkg/errors"
+)
+
+const (
+	testLastValidBlockHeight = 100
+	statusProcessed          = `{"slot":1,"confirmationStatus":"processed"}`
+	statusFinalized          = `{"slot":1,"confirmationStatus":"finalized"}`
+)
+
+// chainServer is a JSON-RPC server that serves a new blockhash for every request of one and the given signature
+// statuses of sent transactions, one per request and the last one repeatedly. A status of "null" is served for
+// transactions the cluster does not know.
+type chainServer struct {
+	mtx         sync.Mutex
+	statuses    []string // The statuses served from the recent status cache.
+	history     string   // The status served when searching the ledger, "null" if empty.
+	blockHeight uint64   // The finalized block height.
+	onStatus    func()   // Called on every status request if set.
+
+	blockhashes  int // The number of served blockhashes.
+	sent         int // The number of sent transactions.
+	statusChecks int // The number of status requests of the recent status cache.
+}
+
+func (s *chainServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if req.Method == "getSignatureStatuses" && s.onStatus != nil {
+		s.onStatus()
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var result string
+	switch req.Method {
+	case "getLatestBlockhash":
+		s.blockhashes++
+		result = fmt.Sprintf(`{"context":{"slot":1},"value":{"blockhash":"%s","lastValidBlockHeight":%d}}`,
+			solana.Hash{byte(s.blockhashes)}, testLastValidBlockHeight)
+	case "sendTransaction":
+		s.sent++
+		result = fmt.Sprintf(`"%s"`, solana.Signature{})
+	case "getSignatureStatuses":
+		var opts struct {
+			SearchTransactionHistory bool `json:"searchTransactionHistory"`
+		}
+		if len(req.Params) > 1 {
+			_ = json.Unmarshal(req.Params[1], &opts)
+		}
+		status := s.history
+		if !opts.SearchTransactionHistory {
+			status = s.statuses[min(s.statusChecks, len(s.statuses)-1)]
+			s.statusChecks++
+		}
+		if status == "" {
+			status = "null"
+		}
+		result = fmt.Sprintf(`{"context":{"slot":1},"value":[%s]}`, status)
+	case "getBlockHeight":
+		result = fmt.Sprint(s.blockHeight)
+	default:
+		http.Error(w, "unexpected method "+req.Method, http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+}
+
+// newChainBackend returns a contract backend connected to the given server, which does not simulate transactions.
+func newChainBackend(t *testing.T, server *chainServer, confirmTimeout time.Duration) *ContractBackend {
+	t.Helper()
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	cluster := LocalnetConfig()
+	cluster.RPCURL = httpServer.URL
+	cluster.Timeouts.Confirm = Duration(confirmTimeout)
+	cluster.Timeouts.RebroadcastInterval = Duration(time.Millisecond)
+	cb, err := NewContractBackendWithCluster(*NewRandomConfig(rand.New(rand.NewSource(1))), channel.BackendID, cluster) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.SetPreflight(false)
+	return cb
+}
+
+func TestSubmitInstructions(t *testing.T) {
+	failed := `{"slot":1,"err":{"InstructionError":[0,{"Custom":1}]},"confirmationStatus":"processed"}`
+
+	tests := []struct {
+		name           string
+		server         *chainServer
+		confirmTimeout time.Duration
+		wantErr        error
+		blockhashes    int // The number of times the transaction is signed.
+	}{
+		{name: "confirmed", server: &chainServer{statuses: []string{statusFinalized}}, blockhashes: 1},
+		{
+			name:        "pending until confirmed",
+			server:      &chainServer{statuses: []string{"null", statusProcessed, statusProcessed, statusFinalized}},
+			blockhashes: 1,
+		},
+		{
+			name:        "pending after expiry",
+			server:      &chainServer{statuses: []string{statusProcessed, statusFinalized}, blockHeight: 200},
+			blockhashes: 1,
+		},
+		{
+			name:        "re-signed after expiry",
+			server:      &chainServer{statuses: []string{"null", statusFinalized}, blockHeight: 200},
+			blockhashes: 2,
+		},
+		{
+			name:        "landed after expiry",
+			server:      &chainServer{statuses: []string{"null"}, history: statusFinalized, blockHeight: 200},
+			blockhashes: 1,
+		},
+		{
+			name:        "expired after re-signings",
+			server:      &chainServer{statuses: []string{"null"}, blockHeight: 200},
+			wantErr:     ErrTxExpired,
+			blockhashes: MaxResigns + 1,
+		},
+		{name: "failed", server: &chainServer{statuses: []string{failed}}, wantErr: ErrTxFailed, blockhashes: 1},
+		{
+			name:           "confirm timeout",
+			server:         &chainServer{statuses: []string{statusProcessed}, blockHeight: 50},
+			confirmTimeout: 20 * time.Millisecond,
+			wantErr:        context.DeadlineExceeded,
+			blockhashes:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmTimeout := tt.confirmTimeout
+			if confirmTimeout == 0 {
+				confirmTimeout = 5 * time.Second
+			}
+			cb := newChainBackend(t, tt.server, confirmTimeout)
+			transfer, err := system.NewTransferInstruction(1, cb.FeePayer(), solana.PublicKey{1}).ValidateAndBuild()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = cb.submitInstructions(context.Background(), []solana.Instruction{transfer})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.server.blockhashes != tt.blockhashes {
+				t.Fatalf("signed %d times, want %d", tt.server.blockhashes, tt.blockhashes)
+			}
+			if tt.server.sent < tt.blockhashes {
+				t.Fatalf("sent %d transactions, want at least %d", tt.server.sent, tt.blockhashes)
+			}
+		})
+	}
+}
+
+func TestInvokeAndConfirmSignedTx(t *testing.T) {
+	server := &chainServer{statuses: []string{statusProcessed, statusProcessed, statusFinalized}, blockHeight: 200}
+	cb := newChainBackend(t, server, 5*time.Second)
+	var locked atomic.Bool
+	server.onStatus = func() {
+		if !cb.cbMutex.TryLock() {
+			locked.Store(true)
+			return
+		}
+		cb.cbMutex.Unlock()
+	}
+	transfer, err := system.NewTransferInstruction(1, cb.FeePayer(), solana.PublicKey{1}).ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := solana.NewTransaction([]solana.Instruction{transfer}, solana.Hash{1}, solana.TransactionPayer(cb.FeePayer()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := cb.InvokeAndConfirmSignedTx(context.Background(), tx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sig != tx.Signatures[0] {
+		t.Fatalf("got signature %s, want %s", sig, tx.Signatures[0])
+	}
+	if locked.Load() {
+		t.Fatal("backend mutex held while awaiting confirmation")
+	}
+	if server.blockhashes != 0 {
+		t.Fatal("signed transaction re-signed with a fresh blockhash")
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/pkg/errors"
+)
+
+const (
+	mintDecimalsOffset = 44  // Offset of the decimals in the base mint layout.
+	mintBaseLength     = 82  // Length of the base mint layout.
+	accountTypeOffset  = 165 // Offset of the account type of Token-2022 accounts with extensions.
+	accountTypeMint    = 1
+
+	extensionTransferFeeConfig = 1
+	extensionNonTransferable   = 9
+	extensionPermanentDelegate = 12
+	extensionTransferHook      = 14
+
+	transferFeeConfigLength = 108
+	maxFeeBasisPoints       = 10_000
+)
+
+// ErrUnsupportedMint is returned for mints whose token program or extensions cannot be used in a channel.
+var ErrUnsupportedMint = errors.New("unsupported mint")
+
+// unsupportedExtensions are the Token-2022 extensions that prevent moving tokens into and out of the channel vault.
+var unsupportedExtensions = map[uint16]string{
+	extensionNonTransferable:   "non-transferable",
+	extensionPermanentDelegate: "permanent delegate",
+	extensionTransferHook:      "transfer hook",
+}
+
+// MintInfo describes an SPL token mint as far as it is relevant for moving its tokens in a channel.
+type MintInfo struct {
+	Mint         solana.PublicKey
+	TokenProgram solana.PublicKey // The token program owning the mint, either the classic or the Token-2022 program.
+	Decimals     uint8
+	TransferFee  *TransferFeeConfig // Nil if the mint has no transfer fee extension.
+}
+
+// TransferFee is a transfer fee of a Token-2022 mint that applies from the given epoch on.
+type TransferFee struct {
+	Epoch       uint64
+	MaximumFee  uint64
+	BasisPoints uint16
+}
+
+// TransferFeeConfig is the transfer fee extension of a Token-2022 mint.
+type TransferFeeConfig struct {
+	Older TransferFee
+	Newer TransferFee
+}
+
+// Fee returns the transfer fee that applies in the given epoch.
+func (c TransferFeeConfig) Fee(epoch uint64) TransferFee {
+	if epoch >= c.Newer.Epoch {
+		return c.Newer
+	}
+	return c.Older
+}
+
+// GrossAmount returns the amount that has to be transferred in the given epoch so that the receiver gets the given
+// net amount after the transfer fee is withheld. It fails if the gross amount does not fit into 64 bits.
+func (c TransferFeeConfig) GrossAmount(net uint64, epoch uint64) (uint64, error) {
+	fee := c.Fee(epoch)
+	if net == 0 || fee.BasisPoints == 0 {
+		return net, nil
+	}
+	withheld := fee.MaximumFee
+	if fee.BasisPoints < maxFeeBasisPoints {
+		// The fee is the rounded up share of the gross amount, so invert it rounding up. The quotient only fits into
+		// 64 bits if the high word of the dividend is smaller than the divisor.
+		divisor := uint64(maxFeeBasisPoints - fee.BasisPoints)
+		hi, lo := bits.Mul64(net, maxFeeBasisPoints)
+		lo, carry := bits.Add64(lo, divisor-1, 0)
+		hi += carry
+		if hi < divisor {
+			gross, _ := bits.Div64(hi, lo, divisor)
+			withheld = min(gross-net, fee.MaximumFee)
+		}
+	}
+	gross, carry := bits.Add64(net, withheld, 0)
+	if carry != 0 {
+		return 0, errors.Errorf("gross amount for net amount %d with fee %d overflows", net, withheld)
+	}
+	return gross, nil
+}
+
+// AssociatedTokenAddress computes the associated token account of the given owner for the given mint owned by the
+// given token program.
+func AssociatedTokenAddress(owner solana.PublicKey, mint solana.PublicKey, tokenProgram solana.PublicKey) (solana.PublicKey, error) {
+	ata, _, err := solana.FindProgramAddress([][]byte{
+		owner[:],
+		tokenProgram[:],
+		mint[:],
+	}, solana.SPLAssociatedTokenAccountProgramID)
+	if err != nil {
+		return solana.PublicKey{}, errors.Wrap(err, "could not find associated token address")
+	}
+	return ata, nil
+}
+
+// GetMintInfo fetches the given mint and returns its token program and transfer fee. It returns ErrUnsupportedMint if
+// the mint is not owned by a token program or uses an extension the channel cannot support.
+func (cb *ContractBackend) GetMintInfo(ctx context.Context, mint solana.PublicKey) (MintInfo, error) {
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return MintInfo{}, err
+	}
+	accountInfo, err := rpcClient.GetAccountInfoWithOpts(ctx, mint, &rpc.GetAccountInfoOpts{
+		Commitment: cb.cluster.Commitments.Read,
+	})
+	if err != nil {
+		return MintInfo{}, errors.Wrapf(err, "could not get mint %s", mint)
+	}
+	if accountInfo == nil || accountInfo.Value == nil {
+		return MintInfo{}, errors.Errorf("mint %s not found", mint)
+	}
+	return DecodeMint(mint, accountInfo.Value.Owner, accountInfo.Value.Data.GetBinary())
+}
+
+// DecodeMint decodes the data of the given mint account owned by the given program.
+func DecodeMint(mint solana.PublicKey, owner solana.PublicKey, data []byte) (MintInfo, error) {
+	if !owner.Equals(solana.TokenProgramID) && !owner.Equals(solana.Token2022ProgramID) {
+		return MintInfo{}, errors.Wrapf(ErrUnsupportedMint, "mint %s is owned by %s, which is not a token program", mint, owner)
+	}
+	if len(data) < mintBaseLength {
+		return MintInfo{}, errors.Wrapf(ErrCouldNotDecodeTx, "mint %s has invalid length %d", mint, len(data))
+	}
+	info := MintInfo{
+		Mint:         mint,
+		TokenProgram: owner,
+		Decimals:     data[mintDecimalsOffset],
+	}
+	if len(data) <= accountTypeOffset {
+		return info, nil // No extensions.
+	}
+	if data[accountTypeOffset] != accountTypeMint {
+		return MintInfo{}, errors.Wrapf(ErrCouldNotDecodeTx, "account %s is not a mint", mint)
+	}
+
+	// The extensions are encoded as type (u16), length (u16) and value.
+	for tlv := data[accountTypeOffset+1:]; len(tlv) >= 4; { //nolint:gomnd
+		extType := binary.LittleEndian.Uint16(tlv[0:2])
+		extLen := int(binary.LittleEndian.Uint16(tlv[2:4]))
+		if len(tlv) < 4+extLen {
+			return MintInfo{}, errors.Wrapf(ErrCouldNotDecodeTx, "mint %s has a truncated extension", mint)
+		}
+		value := tlv[4 : 4+extLen]
+		tlv = tlv[4+extLen:]
+
+		if name, ok := unsupportedExtensions[extType]; ok {
+			return MintInfo{}, errors.Wrapf(ErrUnsupportedMint, "mint %s uses the %s extension", mint, name)
+		}
+		if extType == extensionTransferFeeConfig {
+			if len(value) != transferFeeConfigLength {
+				return MintInfo{}, errors.Wrapf(ErrCouldNotDecodeTx, "mint %s has an invalid transfer fee config", mint)
+			}
+			// Skip the authorities and the withheld amount.
+			info.TransferFee = &TransferFeeConfig{
+				Older: decodeTransferFee(value[72:90]),
+				Newer: decodeTransferFee(value[90:108]),
+			}
+		}
+	}
+	return info, nil
+}
+
+func decodeTransferFee(data []byte) TransferFee {
+	return TransferFee{
+		Epoch:       binary.LittleEndian.Uint64(data[0:8]),
+		MaximumFee:  binary.LittleEndian.Uint64(data[8:16]),
+		BasisPoints: binary.LittleEndian.Uint16(data[16:18]),
+	}
+}
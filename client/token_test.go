@@ -0,0 +1,245 @@
+package client
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/pkg/errors"
+)
+
+// withheldFee computes the transfer fee Token-2022 withholds from the given gross amount.
+func withheldFee(gross uint64, fee TransferFee) uint64 {
+	share := new(big.Int).Mul(new(big.Int).SetUint64(gross), big.NewInt(int64(fee.BasisPoints)))
+	share.Add(share, big.NewInt(maxFeeBasisPoints-1))
+	share.Div(share, big.NewInt(maxFeeBasisPoints))
+	if !share.IsUint64() || share.Uint64() > fee.MaximumFee {
+		return fee.MaximumFee
+	}
+	return share.Uint64()
+}
+
+func TestGrossAmount(t *testing.T) {
+	config := func(basisPoints uint16, maximumFee uint64) TransferFeeConfig {
+		fee := TransferFee{BasisPoints: basisPoints, MaximumFee: maximumFee}
+		return TransferFeeConfig{Older: fee, Newer: fee}
+	}
+
+	tests := []struct {
+		name    string
+		config  TransferFeeConfig
+		net     uint64
+		epoch   uint64
+		want    uint64
+		wantErr bool
+	}{
+		{name: "zero amount", config: config(100, 1_000), net: 0, want: 0},
+		{name: "no fee", config: config(0, 1_000), net: 10_000, want: 10_000},
+		{name: "rounded up fee", config: config(100, 1_000), net: 10_000, want: 10_102},
+		{name: "fee capped at maximum", config: config(100, 50), net: 10_000, want: 10_050},
+		{name: "full basis points", config: config(maxFeeBasisPoints, 7), net: 10_000, want: 10_007},
+		{
+			name:   "older fee before newer epoch",
+			config: TransferFeeConfig{Older: TransferFee{BasisPoints: 100, MaximumFee: 1_000}, Newer: TransferFee{Epoch: 10, BasisPoints: 200, MaximumFee: 1_000}},
+			net:    10_000,
+			epoch:  9,
+			want:   10_102,
+		},
+		{
+			name:   "newer fee from its epoch",
+			config: TransferFeeConfig{Older: TransferFee{BasisPoints: 100, MaximumFee: 1_000}, Newer: TransferFee{Epoch: 10, BasisPoints: 200, MaximumFee: 1_000}},
+			net:    10_000,
+			epoch:  10,
+			want:   10_205,
+		},
+		{name: "product exceeds 64 bits", config: config(1, math.MaxUint64), net: 1 << 62, want: 1<<62 + 461_214_723_315_071},
+		{name: "quotient exceeds 64 bits but fee capped", config: config(5_000, 5), net: math.MaxUint64 - 5, want: math.MaxUint64},
+		{name: "gross exceeds 64 bits", config: config(100, 10), net: math.MaxUint64 - 5, wantErr: true},
+		{name: "full basis points exceed 64 bits", config: config(maxFeeBasisPoints, 10), net: math.MaxUint64 - 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.GrossAmount(tt.net, tt.epoch)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got gross amount %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got gross amount %d, want %d", got, tt.want)
+			}
+			if received := got - withheldFee(got, tt.config.Fee(tt.epoch)); received != tt.net {
+				t.Fatalf("receiver gets %d after fees, want %d", received, tt.net)
+			}
+		})
+	}
+}
+
+// mintData builds the data of a mint account with the given decimals. Without extensions it has the base mint length,
+// otherwise the base mint is padded to the account type offset and followed by the account type and extensions.
+func mintData(decimals uint8, accountType byte, extensions ...[]byte) []byte {
+	data := make([]byte, mintBaseLength)
+	data[mintDecimalsOffset] = decimals
+	if accountType == 0 {
+		return data
+	}
+	data = append(data, make([]byte, accountTypeOffset-mintBaseLength)...)
+	data = append(data, accountType)
+	for _, ext := range extensions {
+		data = append(data, ext...)
+	}
+	return data
+}
+
+// extension encodes a Token-2022 extension as type, length and value.
+func extension(extType uint16, value []byte) []byte {
+	ext := binary.LittleEndian.AppendUint16(nil, extType)
+	ext = binary.LittleEndian.AppendUint16(ext, uint16(len(value)))
+	return append(ext, value...)
+}
+
+// transferFeeExtension encodes a transfer fee config extension with the given fees and non-zero authorities and
+// withheld amount.
+func transferFeeExtension(older TransferFee, newer TransferFee) []byte {
+	value := make([]byte, 72) //nolint:gomnd
+	for i := range value {
+		value[i] = 0xff
+	}
+	for _, fee := range []TransferFee{older, newer} {
+		value = binary.LittleEndian.AppendUint64(value, fee.Epoch)
+		value = binary.LittleEndian.AppendUint64(value, fee.MaximumFee)
+		value = binary.LittleEndian.AppendUint16(value, fee.BasisPoints)
+	}
+	return extension(extensionTransferFeeConfig, value)
+}
+
+func TestDecodeMint(t *testing.T) {
+	mint := solana.PublicKey{1}
+	older := TransferFee{Epoch: 1, MaximumFee: 2, BasisPoints: 3}
+	newer := TransferFee{Epoch: 4, MaximumFee: 5, BasisPoints: 6}
+	mintCloseAuthority := extension(3, make([]byte, 32)) //nolint:gomnd
+
+	tests := []struct {
+		name    string
+		owner   solana.PublicKey
+		data    []byte
+		want    MintInfo
+		wantErr error
+	}{
+		{
+			name:  "classic mint",
+			owner: solana.TokenProgramID,
+			data:  mintData(6, 0),
+			want:  MintInfo{Mint: mint, TokenProgram: solana.TokenProgramID, Decimals: 6},
+		},
+		{
+			name:  "token-2022 mint without extensions",
+			owner: solana.Token2022ProgramID,
+			data:  mintData(9, 0),
+			want:  MintInfo{Mint: mint, TokenProgram: solana.Token2022ProgramID, Decimals: 9},
+		},
+		{
+			name:  "transfer fee",
+			owner: solana.Token2022ProgramID,
+			data:  mintData(2, accountTypeMint, transferFeeExtension(older, newer)),
+			want: MintInfo{
+				Mint:         mint,
+				TokenProgram: solana.Token2022ProgramID,
+				Decimals:     2,
+				TransferFee:  &TransferFeeConfig{Older: older, Newer: newer},
+			},
+		},
+		{
+			name:  "transfer fee after other extension and before padding",
+			owner: solana.Token2022ProgramID,
+			data:  mintData(2, accountTypeMint, mintCloseAuthority, transferFeeExtension(older, newer), make([]byte, 8)),
+			want: MintInfo{
+				Mint:         mint,
+				TokenProgram: solana.Token2022ProgramID,
+				Decimals:     2,
+				TransferFee:  &TransferFeeConfig{Older: older, Newer: newer},
+			},
+		},
+		{
+			name:  "supported extension only",
+			owner: solana.Token2022ProgramID,
+			data:  mintData(2, accountTypeMint, mintCloseAuthority),
+			want:  MintInfo{Mint: mint, TokenProgram: solana.Token2022ProgramID, Decimals: 2},
+		},
+		{
+			name:    "not a token program",
+			owner:   solana.SystemProgramID,
+			data:    mintData(6, 0),
+			wantErr: ErrUnsupportedMint,
+		},
+		{
+			name:    "too short",
+			owner:   solana.TokenProgramID,
+			data:    make([]byte, mintBaseLength-1),
+			wantErr: ErrCouldNotDecodeTx,
+		},
+		{
+			name:    "token account instead of mint",
+			owner:   solana.Token2022ProgramID,
+			data:    mintData(2, 2), //nolint:gomnd
+			wantErr: ErrCouldNotDecodeTx,
+		},
+		{
+			name:    "truncated extension",
+			owner:   solana.Token2022ProgramID,
+			data:    mintData(2, accountTypeMint, transferFeeExtension(older, newer)[:50]),
+			wantErr: ErrCouldNotDecodeTx,
+		},
+		{
+			name:    "invalid transfer fee length",
+			owner:   solana.Token2022ProgramID,
+			data:    mintData(2, accountTypeMint, extension(extensionTransferFeeConfig, make([]byte, 16))),
+			wantErr: ErrCouldNotDecodeTx,
+		},
+		{
+			name:    "non-transferable",
+			owner:   solana.Token2022ProgramID,
+			data:    mintData(2, accountTypeMint, extension(extensionNonTransferable, nil)),
+			wantErr: ErrUnsupportedMint,
+		},
+		{
+			name:    "permanent delegate",
+			owner:   solana.Token2022ProgramID,
+			data:    mintData(2, accountTypeMint, mintCloseAuthority, extension(extensionPermanentDelegate, make([]byte, 32))),
+			wantErr: ErrUnsupportedMint,
+		},
+		{
+			name:    "transfer hook",
+			owner:   solana.Token2022ProgramID,
+			data:    mintData(2, accountTypeMint, extension(extensionTransferHook, make([]byte, 64))),
+			wantErr: ErrUnsupportedMint,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMint(mint, tt.owner, tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Mint != tt.want.Mint || got.TokenProgram != tt.want.TokenProgram || got.Decimals != tt.want.Decimals {
+				t.Fatalf("got mint %+v, want %+v", got, tt.want)
+			}
+			if (got.TransferFee == nil) != (tt.want.TransferFee == nil) ||
+				(got.TransferFee != nil && *got.TransferFee != *tt.want.TransferFee) {
+				t.Fatalf("got transfer fee %+v, want %+v", got.TransferFee, tt.want.TransferFee)
+			}
+		})
+	}
+}
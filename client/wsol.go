@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/pkg/errors"
+	pchannel "perun.network/go-perun/channel"
+)
+
+const (
+	wrappedSOLDecimals = 9   // Number of decimals of the wrapped SOL mint.
+	tokenAccountSize   = 165 // Size of a token account of the classic token program.
+)
+
+// wrappedSOLMint is the mint of wrapped SOL, which is owned by the classic token program.
+var wrappedSOLMint = MintInfo{
+	Mint:         solana.WrappedSol,
+	TokenProgram: solana.TokenProgramID,
+	Decimals:     wrappedSOLDecimals,
+}
+
+// SetWrapSOL sets whether native SOL is moved into and out of channels as wrapped SOL. If set, native SOL is held in
+// a wrapped SOL vault like any SPL token, and so is wrapped SOL. Funding wraps the deposit into a temporary wrapped SOL
+// account of the participant and withdrawing pays out into such an account, which is closed again in the same
+// transaction to unwrap the payout. Wrapped SOL held in the participant's associated token account is not touched. A
+// payout to the other party by a single withdrawer is made in wrapped SOL to its associated token account. Both
+// parties of a channel have to use the same setting.
+func (cb *ContractBackend) SetWrapSOL(wrap bool) {
+	cb.wrapSOLMtx.Lock()
+	defer cb.wrapSOLMtx.Unlock()
+	cb.wrapSOL = wrap
+}
+
+// WrapSOL returns whether native SOL is moved into and out of channels as wrapped SOL, see SetWrapSOL.
+func (cb *ContractBackend) WrapSOL() bool {
+	cb.wrapSOLMtx.Lock()
+	defer cb.wrapSOLMtx.Unlock()
+	return cb.wrapSOL
+}
+
+// TemporaryWrappedSOLAccount returns the temporary wrapped SOL account of the given owner for the given channel and
+// the seed it is derived with from the owner.
+func TemporaryWrappedSOLAccount(owner solana.PublicKey, chanID pchannel.ID) (solana.PublicKey, string, error) {
+	seed := hex.EncodeToString(chanID[:solana.MaxSeedLength/2])
+	account, err := solana.CreateWithSeed(owner, seed, solana.TokenProgramID)
+	if err != nil {
+		return solana.PublicKey{}, "", errors.Wrap(err, "could not derive temporary wrapped SOL account")
+	}
+	return account, seed, nil
+}
+
+// NewCreateTokenAccountInstruction creates an instruction that creates the associated token account of the given
+// owner for the given mint, paid by the participant. It succeeds without changes if the account already exists.
+func (cb *ContractBackend) NewCreateTokenAccountInstruction(owner solana.PublicKey, mint MintInfo) (solana.Instruction, error) {
+	ata, err := AssociatedTokenAddress(owner, mint.Mint, mint.TokenProgram)
+	if err != nil {
+		return nil, err
+	}
+	return cb.newCreateIdempotentInstruction(ata, owner, mint), nil
+}
+
+// NewWrapSOLInstructions creates the instructions that create the temporary wrapped SOL account of the participant for
+// the given channel, holding the given amount of lamports as wrapped SOL.
+func (cb *ContractBackend) NewWrapSOLInstructions(ctx context.Context, chanID pchannel.ID, amount uint64) ([]solana.Instruction, error) {
+	participant := cb.signer.participant.SolanaAddress
+	account, seed, err := TemporaryWrappedSOLAccount(participant, chanID)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := cb.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	rent, err := rpcClient.GetMinimumBalanceForRentExemption(ctx, tokenAccountSize, cb.cluster.Commitments.Read)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get rent exemption")
+	}
+	lamports := rent + amount
+	if lamports < rent {
+		return nil, errors.New("wrapped lamports overflow")
+	}
+	createIx, err := system.NewCreateAccountWithSeedInstruction(
+		participant,
+		seed,
+		lamports,
+		tokenAccountSize,
+		solana.TokenProgramID,
+		participant,
+		account,
+		participant,
+	).ValidateAndBuild()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create account instruction")
+	}
+	initIx, err := token.NewInitializeAccount3Instruction(participant, account, wrappedSOLMint.Mint).ValidateAndBuild()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create initialize account instruction")
+	}
+	return []solana.Instruction{createIx, initIx}, nil
+}
+
+// NewUnwrapSOLInstruction creates an instruction that closes the temporary wrapped SOL account of the participant for
+// the given channel, which unwraps its wrapped SOL and returns its rent to the participant.
+func (cb *ContractBackend) NewUnwrapSOLInstruction(chanID pchannel.ID) (solana.Instruction, error) {
+	participant := cb.signer.participant.SolanaAddress
+	account, _, err := TemporaryWrappedSOLAccount(participant, chanID)
+	if err != nil {
+		return nil, err
+	}
+	closeIx, err := token.NewCloseAccountInstruction(account, participant, participant, nil).ValidateAndBuild()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create close account instruction")
+	}
+	return closeIx, nil
+}
@@ -0,0 +1,387 @@
+package encoding
+
+import (
+	"sync/atomic"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/pkg/errors"
+)
+
+// Plain ledger channels, which have no app, no locked funds and are not virtual, are encoded in the original layout of
+// the Perun program accounts and instructions, so they stay compatible with deployments of the program that predate
+// app channels, sub-channels, virtual channels and transfer fees. The fields added for these features are only encoded
+// for channels that use them, as an extension after the fields of the original layout. The extension is prefixed by a
+// Borsh option flag, so missing data and trailing zero bytes, such as unused account space, read as no extension.
+// Channels that use the extension require a Perun program that decodes it, so the extension is only encoded and decoded
+// after it has been enabled with EnableExtendedLayout.
+
+// ErrExtendedLayoutDisabled is returned when encoding data that needs the extended layout while it is disabled.
+var ErrExtendedLayoutDisabled = errors.New("extended layout is disabled")
+
+// extendedLayout reports whether the extended layout is enabled.
+var extendedLayout atomic.Bool
+
+// EnableExtendedLayout enables or disables the extended layout. It must only be enabled if the deployed Perun program
+// decodes the extension.
+func EnableExtendedLayout(enable bool) {
+	extendedLayout.Store(enable)
+}
+
+// paramsBase is the original layout of Params.
+type paramsBase struct {
+	A                 Participant
+	B                 Participant
+	Nonce             [32]byte
+	ChallengeDuration uint64
+}
+
+// paramsExtension holds the fields of Params that are not part of the original layout.
+type paramsExtension struct {
+	App            solana.PublicKey
+	LedgerChannel  bool
+	VirtualChannel bool
+}
+
+// balancesBase is the original layout of Balances.
+type balancesBase struct {
+	Tokens []CrossAsset
+	BalA   []uint64
+	BalB   []uint64
+}
+
+// stateBase is the original layout of ChannelState.
+type stateBase struct {
+	ChannelID [32]byte
+	Balances  balancesBase
+	Version   uint64
+	Finalized bool
+}
+
+// stateExtension holds the fields of ChannelState that are not part of the original layout.
+type stateExtension struct {
+	AppData []byte
+	Locked  []SubAlloc
+}
+
+// channelExtension is the extension of accounts and instructions that hold both the parameters and a state.
+type channelExtension struct {
+	Params paramsExtension
+	State  stateExtension
+}
+
+// fundExtension is the extension of the fund instruction.
+type fundExtension struct {
+	Amounts []uint64
+}
+
+// forceCloseExtension is the extension of the force close instruction.
+type forceCloseExtension struct {
+	SubChannels [][32]byte
+}
+
+// disputeExtension is the extension of the dispute instruction.
+type disputeExtension struct {
+	State       stateExtension
+	SubChannels []SubChannelDispute
+}
+
+func (p Params) base() paramsBase {
+	return paramsBase{
+		A:                 p.A,
+		B:                 p.B,
+		Nonce:             p.Nonce,
+		ChallengeDuration: p.ChallengeDuration,
+	}
+}
+
+func (p Params) extension() paramsExtension {
+	return paramsExtension{
+		App:            p.App,
+		LedgerChannel:  p.LedgerChannel,
+		VirtualChannel: p.VirtualChannel,
+	}
+}
+
+// plainParamsExtension returns the extension implied by the original layout, which only holds ledger channels.
+func plainParamsExtension() paramsExtension {
+	return paramsExtension{LedgerChannel: true}
+}
+
+func (e paramsExtension) isPlain() bool {
+	return e == plainParamsExtension()
+}
+
+func makeParams(base paramsBase, ext paramsExtension) Params {
+	return Params{
+		A:                 base.A,
+		B:                 base.B,
+		Nonce:             base.Nonce,
+		ChallengeDuration: base.ChallengeDuration,
+		App:               ext.App,
+		LedgerChannel:     ext.LedgerChannel,
+		VirtualChannel:    ext.VirtualChannel,
+	}
+}
+
+func (s ChannelState) base() stateBase {
+	return stateBase{
+		ChannelID: s.ChannelID,
+		Balances: balancesBase{
+			Tokens: s.Balances.Tokens,
+			BalA:   s.Balances.BalA,
+			BalB:   s.Balances.BalB,
+		},
+		Version:   s.Version,
+		Finalized: s.Finalized,
+	}
+}
+
+func (s ChannelState) extension() stateExtension {
+	return stateExtension{
+		AppData: s.AppData,
+		Locked:  s.Balances.Locked,
+	}
+}
+
+func (e stateExtension) isPlain() bool {
+	return len(e.AppData) == 0 && len(e.Locked) == 0
+}
+
+func makeChannelState(base stateBase, ext stateExtension) ChannelState {
+	return ChannelState{
+		ChannelID: base.ChannelID,
+		Balances: Balances{
+			Tokens: base.Balances.Tokens,
+			BalA:   base.Balances.BalA,
+			BalB:   base.Balances.BalB,
+			Locked: ext.Locked,
+		},
+		Version:   base.Version,
+		Finalized: base.Finalized,
+		AppData:   ext.AppData,
+	}
+}
+
+func (e channelExtension) isPlain() bool {
+	return e.Params.isPlain() && e.State.isPlain()
+}
+
+func (e disputeExtension) isPlain() bool {
+	return e.State.isPlain() && len(e.SubChannels) == 0
+}
+
+// encodeWithExtension encodes the given fields of the original layout, followed by the extension unless it is plain.
+func encodeWithExtension(enc *bin.Encoder, ext interface{}, plain bool, fields ...interface{}) error {
+	for _, field := range fields {
+		if err := enc.Encode(field); err != nil {
+			return err
+		}
+	}
+	if plain {
+		return nil
+	}
+	if !extendedLayout.Load() {
+		return ErrExtendedLayoutDisabled
+	}
+	if err := enc.WriteOption(true); err != nil {
+		return err
+	}
+	return enc.Encode(ext)
+}
+
+// decodeWithExtension decodes the given fields of the original layout, followed by the extension if present and the
+// extended layout is enabled. The extension is left unchanged otherwise.
+func decodeWithExtension(dec *bin.Decoder, ext interface{}, fields ...interface{}) error {
+	for _, field := range fields {
+		if err := dec.Decode(field); err != nil {
+			return err
+		}
+	}
+	if !extendedLayout.Load() || !dec.HasRemaining() {
+		return nil
+	}
+	present, err := dec.ReadOption()
+	if err != nil {
+		return errors.Wrap(err, "failed to read extension flag")
+	}
+	if !present {
+		return nil
+	}
+	return dec.Decode(ext)
+}
+
+// MarshalWithEncoder encodes the instruction as Borsh enum. The generic enum encoding of the binary package ignores
+// the marshalers of the variants, so the selected variant is encoded explicitly.
+func (p PerunInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	var variant interface{}
+	switch p.Enum {
+	case 0:
+		variant = p.Open
+	case 1:
+		variant = p.Fund
+	case 2: //nolint:gomnd
+		variant = p.Close
+	case 3: //nolint:gomnd
+		variant = p.ForceClose
+	case 4: //nolint:gomnd
+		variant = p.Dispute
+	case 5: //nolint:gomnd
+		variant = p.Withdraw
+	case 6: //nolint:gomnd
+		variant = p.AbortFunding
+	case 7: //nolint:gomnd
+		variant = p.Progress
+	default:
+		return errors.Errorf("unknown perun instruction %d", p.Enum)
+	}
+	if err := enc.WriteUint8(uint8(p.Enum)); err != nil {
+		return err
+	}
+	return enc.Encode(variant)
+}
+
+// MarshalWithEncoder encodes the channel account, in the original layout for plain ledger channels.
+func (c Channel) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := channelExtension{Params: c.Params.extension(), State: c.State.extension()}
+	return encodeWithExtension(enc, ext, ext.isPlain(), c.Params.base(), c.State.base(), c.Control)
+}
+
+// UnmarshalWithDecoder decodes the channel account.
+func (c *Channel) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var params paramsBase
+	var state stateBase
+	ext := channelExtension{Params: plainParamsExtension()}
+	if err := decodeWithExtension(dec, &ext, &params, &state, &c.Control); err != nil {
+		return err
+	}
+	c.Params = makeParams(params, ext.Params)
+	c.State = makeChannelState(state, ext.State)
+	return nil
+}
+
+// MarshalWithEncoder encodes the open instruction, in the original layout for plain ledger channels.
+func (o OpenInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := channelExtension{Params: o.Params.extension(), State: o.State.extension()}
+	return encodeWithExtension(enc, ext, ext.isPlain(), o.Params.base(), o.State.base())
+}
+
+// UnmarshalWithDecoder decodes the open instruction.
+func (o *OpenInstruction) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var params paramsBase
+	var state stateBase
+	ext := channelExtension{Params: plainParamsExtension()}
+	if err := decodeWithExtension(dec, &ext, &params, &state); err != nil {
+		return err
+	}
+	o.Params = makeParams(params, ext.Params)
+	o.State = makeChannelState(state, ext.State)
+	return nil
+}
+
+// MarshalWithEncoder encodes the close instruction, in the original layout for plain ledger channels.
+func (c CloseInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := c.State.extension()
+	return encodeWithExtension(enc, ext, ext.isPlain(), c.State.base(), c.SigA, c.SigB)
+}
+
+// UnmarshalWithDecoder decodes the close instruction.
+func (c *CloseInstruction) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var state stateBase
+	var ext stateExtension
+	if err := decodeWithExtension(dec, &ext, &state, &c.SigA, &c.SigB); err != nil {
+		return err
+	}
+	c.State = makeChannelState(state, ext)
+	return nil
+}
+
+// MarshalWithEncoder encodes the fund instruction, in the original layout if no amounts including transfer fees are
+// given.
+func (f FundInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := fundExtension{Amounts: f.Amounts}
+	return encodeWithExtension(enc, ext, len(ext.Amounts) == 0, f.ChannelID, f.PartyIdx)
+}
+
+// UnmarshalWithDecoder decodes the fund instruction.
+func (f *FundInstruction) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var ext fundExtension
+	if err := decodeWithExtension(dec, &ext, &f.ChannelID, &f.PartyIdx); err != nil {
+		return err
+	}
+	f.Amounts = ext.Amounts
+	return nil
+}
+
+// MarshalWithEncoder encodes the force close instruction, in the original layout if no sub-channels are settled.
+func (f ForceCloseInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := forceCloseExtension{SubChannels: f.SubChannels}
+	return encodeWithExtension(enc, ext, len(ext.SubChannels) == 0, f.ChannelID)
+}
+
+// UnmarshalWithDecoder decodes the force close instruction.
+func (f *ForceCloseInstruction) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var ext forceCloseExtension
+	if err := decodeWithExtension(dec, &ext, &f.ChannelID); err != nil {
+		return err
+	}
+	f.SubChannels = ext.SubChannels
+	return nil
+}
+
+// MarshalWithEncoder encodes the dispute instruction, in the original layout for plain ledger channels without
+// sub-channels.
+func (d DisputeInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := disputeExtension{State: d.State.extension(), SubChannels: d.SubChannels}
+	return encodeWithExtension(enc, ext, ext.isPlain(), d.State.base(), d.SigA, d.SigB)
+}
+
+// UnmarshalWithDecoder decodes the dispute instruction.
+func (d *DisputeInstruction) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var state stateBase
+	var ext disputeExtension
+	if err := decodeWithExtension(dec, &ext, &state, &d.SigA, &d.SigB); err != nil {
+		return err
+	}
+	d.State = makeChannelState(state, ext.State)
+	d.SubChannels = ext.SubChannels
+	return nil
+}
+
+// MarshalWithEncoder encodes the dispute of a sub-channel. The disputes of sub-channels are followed by further data,
+// so they always carry the extension.
+func (s SubChannelDispute) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := channelExtension{Params: s.Params.extension(), State: s.State.extension()}
+	return encodeWithExtension(enc, ext, false, s.Params.base(), s.State.base(), s.SigA, s.SigB)
+}
+
+// UnmarshalWithDecoder decodes the dispute of a sub-channel.
+func (s *SubChannelDispute) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var params paramsBase
+	var state stateBase
+	ext := channelExtension{Params: plainParamsExtension()}
+	if err := decodeWithExtension(dec, &ext, &params, &state, &s.SigA, &s.SigB); err != nil {
+		return err
+	}
+	s.Params = makeParams(params, ext.Params)
+	s.State = makeChannelState(state, ext.State)
+	return nil
+}
+
+// MarshalWithEncoder encodes the progress instruction, with the state in the original layout unless it has app data
+// or locked funds.
+func (p ProgressInstruction) MarshalWithEncoder(enc *bin.Encoder) error {
+	ext := p.State.extension()
+	return encodeWithExtension(enc, ext, ext.isPlain(), p.State.base(), p.ActorIdx, p.Sig)
+}
+
+// UnmarshalWithDecoder decodes the progress instruction.
+func (p *ProgressInstruction) UnmarshalWithDecoder(dec *bin.Decoder) error {
+	var state stateBase
+	var ext stateExtension
+	if err := decodeWithExtension(dec, &ext, &state, &p.ActorIdx, &p.Sig); err != nil {
+		return err
+	}
+	p.State = makeChannelState(state, ext)
+	return nil
+}
@@ -0,0 +1,298 @@
+package encoding
+
+import (
+	"bytes"
+	"testing"
+
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/pkg/errors"
+)
+
+// The original layouts of the Perun program accounts and instructions, which plain ledger channels must keep.
+type (
+	originalBalances struct {
+		Tokens []CrossAsset
+		BalA   []uint64
+		BalB   []uint64
+	}
+
+	originalState struct {
+		ChannelID [32]byte
+		Balances  originalBalances
+		Version   uint64
+		Finalized bool
+	}
+
+	originalParams struct {
+		A                 Participant
+		B                 Participant
+		Nonce             [32]byte
+		ChallengeDuration uint64
+	}
+
+	originalChannel struct {
+		Params  originalParams
+		State   originalState
+		Control Control
+	}
+
+	originalInstruction struct {
+		Enum bin.BorshEnum `borsh_enum:"true"`
+		Open struct {
+			Params originalParams
+			State  originalState
+		}
+		Fund struct {
+			ChannelID [32]byte
+			PartyIdx  bool
+		}
+		Close struct {
+			State originalState
+			SigA  [65]byte
+			SigB  [65]byte
+		}
+		ForceClose struct{ ChannelID [32]byte }
+		Dispute    struct {
+			State originalState
+			SigA  [65]byte
+			SigB  [65]byte
+		}
+	}
+)
+
+func testParams() Params {
+	return Params{
+		A:                 Participant{SolanaAddress: solana.PublicKey{1}, CcAddress: [20]byte{2}, L2Pubkey: [65]byte{3}},
+		B:                 Participant{SolanaAddress: solana.PublicKey{4}, CcAddress: [20]byte{5}, L2Pubkey: [65]byte{6}},
+		Nonce:             [32]byte{7},
+		ChallengeDuration: 60,
+		LedgerChannel:     true,
+	}
+}
+
+func testState() ChannelState {
+	return ChannelState{
+		ChannelID: [32]byte{8},
+		Balances: Balances{
+			Tokens: []CrossAsset{{Chain: 6}, {Chain: 1, EthAddress: [20]byte{9}}},
+			BalA:   []uint64{10, 11},
+			BalB:   []uint64{12, 13},
+		},
+		Version:   14,
+		Finalized: true,
+	}
+}
+
+func originalOf(params Params, state ChannelState) (originalParams, originalState) {
+	return originalParams{
+		A:                 params.A,
+		B:                 params.B,
+		Nonce:             params.Nonce,
+		ChallengeDuration: params.ChallengeDuration,
+	}, originalState{
+		ChannelID: state.ChannelID,
+		Balances: originalBalances{
+			Tokens: state.Balances.Tokens,
+			BalA:   state.Balances.BalA,
+			BalB:   state.Balances.BalB,
+		},
+		Version:   state.Version,
+		Finalized: state.Finalized,
+	}
+}
+
+// enableExtendedLayout enables the extended layout for the duration of the test.
+func enableExtendedLayout(t *testing.T) {
+	t.Helper()
+	EnableExtendedLayout(true)
+	t.Cleanup(func() { EnableExtendedLayout(false) })
+}
+
+func mustEncode(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := bin.NewBorshEncoder(buf).Encode(v); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPlainLedgerChannelKeepsOriginalLayout(t *testing.T) {
+	params, state := testParams(), testState()
+	oParams, oState := originalOf(params, state)
+	control := Control{FundedA: true, Disputed: true, Timestamp: 15}
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		original interface{}
+	}{
+		{
+			name:     "channel account",
+			value:    Channel{Params: params, State: state, Control: control},
+			original: originalChannel{Params: oParams, State: oState, Control: control},
+		},
+		{
+			name:  "open",
+			value: PerunInstruction{Enum: 0, Open: OpenInstruction{Params: params, State: state}},
+			original: func() originalInstruction {
+				instr := originalInstruction{Enum: 0}
+				instr.Open.Params, instr.Open.State = oParams, oState
+				return instr
+			}(),
+		},
+		{
+			name:  "dispute",
+			value: PerunInstruction{Enum: 4, Dispute: DisputeInstruction{State: state, SigA: [65]byte{16}, SigB: [65]byte{17}}},
+			original: func() originalInstruction {
+				instr := originalInstruction{Enum: 4}
+				instr.Dispute.State, instr.Dispute.SigA, instr.Dispute.SigB = oState, [65]byte{16}, [65]byte{17}
+				return instr
+			}(),
+		},
+		{
+			name:  "fund",
+			value: PerunInstruction{Enum: 1, Fund: FundInstruction{ChannelID: [32]byte{18}, PartyIdx: true}},
+			original: originalInstruction{Enum: 1, Fund: struct {
+				ChannelID [32]byte
+				PartyIdx  bool
+			}{[32]byte{18}, true}},
+		},
+		{
+			name:     "force close",
+			value:    PerunInstruction{Enum: 3, ForceClose: ForceCloseInstruction{ChannelID: [32]byte{18}}},
+			original: originalInstruction{Enum: 3, ForceClose: struct{ ChannelID [32]byte }{ChannelID: [32]byte{18}}},
+		},
+		{
+			name:  "close",
+			value: PerunInstruction{Enum: 2, Close: CloseInstruction{State: state, SigA: [65]byte{16}, SigB: [65]byte{17}}},
+			original: func() originalInstruction {
+				instr := originalInstruction{Enum: 2}
+				instr.Close.State, instr.Close.SigA, instr.Close.SigB = oState, [65]byte{16}, [65]byte{17}
+				return instr
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, want := mustEncode(t, tt.value), mustEncode(t, tt.original)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("encoding differs from original layout:\n got %x\nwant %x", got, want)
+			}
+		})
+	}
+}
+
+func TestExtendedLayoutDisabled(t *testing.T) {
+	app := Channel{Params: testParams(), State: testState()}
+	app.State.AppData = []byte{1}
+	if err := bin.NewBorshEncoder(new(bytes.Buffer)).Encode(app); !errors.Is(err, ErrExtendedLayoutDisabled) {
+		t.Fatalf("got error %v, want %v", err, ErrExtendedLayoutDisabled)
+	}
+
+	enableExtendedLayout(t)
+	data := mustEncode(t, app)
+	EnableExtendedLayout(false)
+	var got Channel
+	if err := bin.NewBorshDecoder(data).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.State.AppData != nil || got.Params != testParams() {
+		t.Fatalf("decoded extension although the extended layout is disabled: %+v", got)
+	}
+}
+
+func TestChannelRoundTrip(t *testing.T) {
+	enableExtendedLayout(t)
+	plain := Channel{Params: testParams(), State: testState(), Control: Control{Closed: true, Timestamp: 1}}
+
+	app := plain
+	app.Params.App = solana.PublicKey{18}
+	app.State.AppData = []byte{19, 20}
+
+	virtual := plain
+	virtual.Params.LedgerChannel = false
+	virtual.Params.VirtualChannel = true
+
+	locked := plain
+	locked.State.Balances.Locked = []SubAlloc{{ID: [32]byte{21}, Balances: []uint64{1, 2}, IndexMap: []uint16{0, 1}}}
+
+	tests := []struct {
+		name    string
+		channel Channel
+		padding int
+	}{
+		{name: "plain", channel: plain},
+		{name: "plain with account padding", channel: plain, padding: 64},
+		{name: "app", channel: app},
+		{name: "app with account padding", channel: app, padding: 64},
+		{name: "virtual", channel: virtual},
+		{name: "locked", channel: locked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(mustEncode(t, tt.channel), make([]byte, tt.padding)...)
+			var got Channel
+			if err := bin.NewBorshDecoder(data).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !bytes.Equal(mustEncode(t, got), mustEncode(t, tt.channel)) {
+				t.Fatalf("decoded channel differs: got %+v, want %+v", got, tt.channel)
+			}
+			if got.Params != tt.channel.Params {
+				t.Fatalf("decoded params differ: got %+v, want %+v", got.Params, tt.channel.Params)
+			}
+		})
+	}
+}
+
+func TestInstructionRoundTrip(t *testing.T) {
+	enableExtendedLayout(t)
+	appState := testState()
+	appState.AppData = []byte{22}
+	appParams := testParams()
+	appParams.App = solana.PublicKey{23}
+	lockedState := testState()
+	lockedState.Balances.Locked = []SubAlloc{{ID: [32]byte{24}, Balances: []uint64{1, 2}, IndexMap: []uint16{0, 1}}}
+	subParams := testParams()
+	subParams.LedgerChannel = false
+
+	tests := []struct {
+		name  string
+		instr PerunInstruction
+	}{
+		{name: "open plain", instr: PerunInstruction{Enum: 0, Open: OpenInstruction{Params: testParams(), State: testState()}}},
+		{name: "open app", instr: PerunInstruction{Enum: 0, Open: OpenInstruction{Params: appParams, State: appState}}},
+		{name: "close plain", instr: PerunInstruction{Enum: 2, Close: CloseInstruction{State: testState(), SigA: [65]byte{1}}}},
+		{name: "close app", instr: PerunInstruction{Enum: 2, Close: CloseInstruction{State: appState, SigB: [65]byte{2}}}},
+		{name: "dispute plain", instr: PerunInstruction{Enum: 4, Dispute: DisputeInstruction{State: testState(), SigA: [65]byte{4}}}},
+		{name: "dispute with sub-channels", instr: PerunInstruction{Enum: 4, Dispute: DisputeInstruction{
+			State: lockedState,
+			SubChannels: []SubChannelDispute{
+				{Params: subParams, State: testState(), SigA: [65]byte{5}},
+				{Params: subParams, State: appState, SigB: [65]byte{6}},
+			},
+		}}},
+		{name: "fund plain", instr: PerunInstruction{Enum: 1, Fund: FundInstruction{ChannelID: [32]byte{5}, PartyIdx: true}}},
+		{name: "fund with amounts", instr: PerunInstruction{Enum: 1, Fund: FundInstruction{ChannelID: [32]byte{6}, Amounts: []uint64{7, 8}}}},
+		{name: "force close plain", instr: PerunInstruction{Enum: 3, ForceClose: ForceCloseInstruction{ChannelID: [32]byte{7}}}},
+		{name: "force close with sub-channels", instr: PerunInstruction{Enum: 3, ForceClose: ForceCloseInstruction{
+			ChannelID:   [32]byte{8},
+			SubChannels: [][32]byte{{9}, {10}},
+		}}},
+		{name: "progress", instr: PerunInstruction{Enum: 7, Progress: ProgressInstruction{State: appState, ActorIdx: true, Sig: [65]byte{3}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustEncode(t, tt.instr)
+			got, err := DecodePerunInstruction(data)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !bytes.Equal(mustEncode(t, got), data) {
+				t.Fatalf("decoded instruction differs: got %+v, want %+v", got, tt.instr)
+			}
+		})
+	}
+}
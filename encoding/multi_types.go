@@ -105,6 +105,33 @@ func MakeAddress(asset *channel.SolanaCrossAsset) (solana.PublicKey, error) {
 	return *asset.Asset.Mint, nil
 }
 
+// MakeApp converts a pchannel.App to the program ID of the app. It returns the zero key for NoApp.
+func MakeApp(app pchannel.App) (solana.PublicKey, error) {
+	if app == nil || pchannel.IsNoApp(app) {
+		return solana.PublicKey{}, nil
+	}
+	appID, err := channel.ToAppID(app)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	return appID.ProgramID, nil
+}
+
+// MakeAppData encodes the data of an app channel. It returns no data for NoApp.
+func MakeAppData(app pchannel.App, data pchannel.Data) ([]byte, error) {
+	if app == nil || pchannel.IsNoApp(app) {
+		if !pchannel.IsNoData(data) {
+			return nil, errors.New("expected NoData")
+		}
+		return nil, nil
+	}
+	appData, err := data.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode app data")
+	}
+	return appData, nil
+}
+
 // Chain represents a chain identifier.
 type Chain uint64
 
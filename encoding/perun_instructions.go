@@ -6,6 +6,7 @@ import (
 	bin "github.com/gagliardetto/binary"
 	"github.com/pkg/errors"
 	pchannel "perun.network/go-perun/channel"
+	pwallet "perun.network/go-perun/wallet"
 )
 
 type PerunInstruction struct {
@@ -17,6 +18,7 @@ type PerunInstruction struct {
 	Dispute      DisputeInstruction
 	Withdraw     WithdrawInstruction
 	AbortFunding AbortFundingInstruction
+	Progress     ProgressInstruction
 }
 
 type OpenInstruction struct {
@@ -27,6 +29,7 @@ type OpenInstruction struct {
 type FundInstruction struct {
 	ChannelID [32]byte
 	PartyIdx  bool
+	Amounts   []uint64 // The amount transferred per token including transfer fees, empty to transfer the balances.
 }
 
 type CloseInstruction struct {
@@ -36,13 +39,25 @@ type CloseInstruction struct {
 }
 
 type ForceCloseInstruction struct {
-	ChannelID [32]byte
+	ChannelID   [32]byte
+	SubChannels [][32]byte // The sub-channels and virtual channels whose registered states settle the locked funds.
 }
 
 type DisputeInstruction struct {
-	State ChannelState
-	SigA  [65]byte
-	SigB  [65]byte
+	State       ChannelState
+	SigA        [65]byte
+	SigB        [65]byte
+	SubChannels []SubChannelDispute
+}
+
+// SubChannelDispute registers the state of a sub-channel or virtual channel together with the dispute of its parent.
+// The participants of a virtual channel are mapped to the parent participants by the index map of the locked
+// sub-allocation in the parent state.
+type SubChannelDispute struct {
+	Params Params
+	State  ChannelState
+	SigA   [65]byte
+	SigB   [65]byte
 }
 
 type WithdrawInstruction struct {
@@ -55,7 +70,18 @@ type AbortFundingInstruction struct {
 	ChannelID [32]byte
 }
 
+// ProgressInstruction progresses a disputed app channel to a new state. The transition is validated by the app
+// program of the channel.
+type ProgressInstruction struct {
+	State    ChannelState
+	ActorIdx bool
+	Sig      [65]byte
+}
+
 func MakeOpenInstruction(params *pchannel.Params, state *pchannel.State) ([]byte, error) {
+	if !params.LedgerChannel {
+		return nil, errors.New("only ledger channels can be opened")
+	}
 	buf := new(bytes.Buffer)
 	enc := bin.NewBorshEncoder(buf)
 
@@ -83,7 +109,7 @@ func MakeOpenInstruction(params *pchannel.Params, state *pchannel.State) ([]byte
 	return buf.Bytes(), nil
 }
 
-func MakeFundInstruction(channelID [32]byte, partyIdx bool) ([]byte, error) {
+func MakeFundInstruction(channelID [32]byte, partyIdx bool, amounts []uint64) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	enc := bin.NewBorshEncoder(buf)
 
@@ -92,6 +118,7 @@ func MakeFundInstruction(channelID [32]byte, partyIdx bool) ([]byte, error) {
 		Fund: FundInstruction{
 			ChannelID: channelID,
 			PartyIdx:  partyIdx,
+			Amounts:   amounts,
 		},
 	}
 	if err := enc.Encode(&instr); err != nil {
@@ -100,3 +127,219 @@ func MakeFundInstruction(channelID [32]byte, partyIdx bool) ([]byte, error) {
 
 	return buf.Bytes(), nil
 }
+
+func MakeDisputeInstruction(state *pchannel.State, sigs []pwallet.Sig, subChannels []pchannel.SignedState) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := bin.NewBorshEncoder(buf)
+
+	bState, err := MakeChannelState(*state) // convert go-perun State to encoding ChannelState
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make channel state")
+	}
+
+	sigA, sigB, err := MakeSigs(sigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make signatures")
+	}
+
+	bSubChannels, err := MakeSubChannelDisputes(subChannels)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make sub-channel disputes")
+	}
+
+	instr := PerunInstruction{
+		Enum: 4,
+		Dispute: DisputeInstruction{
+			State:       bState,
+			SigA:        sigA,
+			SigB:        sigB,
+			SubChannels: bSubChannels,
+		},
+	}
+	if err := enc.Encode(&instr); err != nil {
+		return nil, errors.Wrap(err, "failed to encode dispute instruction")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func MakeCloseInstruction(state *pchannel.State, sigs []pwallet.Sig) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := bin.NewBorshEncoder(buf)
+
+	bState, err := MakeChannelState(*state) // convert go-perun State to encoding ChannelState
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make channel state")
+	}
+
+	sigA, sigB, err := MakeSigs(sigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make signatures")
+	}
+
+	instr := PerunInstruction{
+		Enum: 2,
+		Close: CloseInstruction{
+			State: bState,
+			SigA:  sigA,
+			SigB:  sigB,
+		},
+	}
+	if err := enc.Encode(&instr); err != nil {
+		return nil, errors.Wrap(err, "failed to encode close instruction")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func MakeForceCloseInstruction(channelID [32]byte, subChannels [][32]byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := bin.NewBorshEncoder(buf)
+
+	instr := PerunInstruction{
+		Enum: 3,
+		ForceClose: ForceCloseInstruction{
+			ChannelID:   channelID,
+			SubChannels: subChannels,
+		},
+	}
+	if err := enc.Encode(&instr); err != nil {
+		return nil, errors.Wrap(err, "failed to encode force close instruction")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func MakeWithdrawInstruction(channelID [32]byte, partyIdx bool, oneWithdrawer bool) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := bin.NewBorshEncoder(buf)
+
+	instr := PerunInstruction{
+		Enum: 5,
+		Withdraw: WithdrawInstruction{
+			ChannelID:     channelID,
+			PartyIdx:      partyIdx,
+			OneWithdrawer: oneWithdrawer,
+		},
+	}
+	if err := enc.Encode(&instr); err != nil {
+		return nil, errors.Wrap(err, "failed to encode withdraw instruction")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MakeAbortFundingInstruction creates the instruction data to abort the funding of a channel and refund the deposits
+// made so far.
+func MakeAbortFundingInstruction(channelID [32]byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := bin.NewBorshEncoder(buf)
+
+	instr := PerunInstruction{
+		Enum: 6, //nolint:gomnd
+		AbortFunding: AbortFundingInstruction{
+			ChannelID: channelID,
+		},
+	}
+	if err := enc.Encode(&instr); err != nil {
+		return nil, errors.Wrap(err, "failed to encode abort funding instruction")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MakeProgressInstruction creates the instruction data to progress a disputed app channel to the given state, signed
+// by the participant with the given index.
+func MakeProgressInstruction(state *pchannel.State, actorIdx bool, sig pwallet.Sig) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := bin.NewBorshEncoder(buf)
+
+	bState, err := MakeChannelState(*state) // convert go-perun State to encoding ChannelState
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make channel state")
+	}
+
+	bSig, err := MakeSig(sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make signature")
+	}
+
+	instr := PerunInstruction{
+		Enum: 7, //nolint:gomnd
+		Progress: ProgressInstruction{
+			State:    bState,
+			ActorIdx: actorIdx,
+			Sig:      bSig,
+		},
+	}
+	if err := enc.Encode(&instr); err != nil {
+		return nil, errors.Wrap(err, "failed to encode progress instruction")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MakeSubChannelDisputes converts the signed states of sub-channels to their on-chain representation.
+func MakeSubChannelDisputes(subChannels []pchannel.SignedState) ([]SubChannelDispute, error) {
+	disputes := make([]SubChannelDispute, len(subChannels))
+	for i, sub := range subChannels {
+		if sub.Params == nil || sub.State == nil {
+			return nil, errors.Errorf("sub-channel %d is missing params or state", i)
+		}
+		params, err := MakeParams(*sub.Params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to make params of sub-channel %d", i)
+		}
+		state, err := MakeChannelState(*sub.State)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to make state of sub-channel %d", i)
+		}
+		sigA, sigB, err := MakeSigs(sub.Sigs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to make signatures of sub-channel %d", i)
+		}
+		disputes[i] = SubChannelDispute{
+			Params: params,
+			State:  state,
+			SigA:   sigA,
+			SigB:   sigB,
+		}
+	}
+	return disputes, nil
+}
+
+// DecodePerunInstruction decodes the data of a Perun program instruction.
+func DecodePerunInstruction(data []byte) (PerunInstruction, error) {
+	dec := bin.NewBorshDecoder(data)
+	var instr PerunInstruction
+	if err := dec.Decode(&instr); err != nil {
+		return PerunInstruction{}, errors.Wrap(err, "failed to decode perun instruction")
+	}
+	return instr, nil
+}
+
+// MakeSigs converts the signatures of both channel participants to their on-chain representation.
+func MakeSigs(sigs []pwallet.Sig) ([65]byte, [65]byte, error) {
+	if len(sigs) != 2 { //nolint:gomnd
+		return [65]byte{}, [65]byte{}, errors.New("expected exactly two signatures")
+	}
+	sigA, err := MakeSig(sigs[0])
+	if err != nil {
+		return [65]byte{}, [65]byte{}, errors.Wrap(err, "invalid signature of participant A")
+	}
+	sigB, err := MakeSig(sigs[1])
+	if err != nil {
+		return [65]byte{}, [65]byte{}, errors.Wrap(err, "invalid signature of participant B")
+	}
+	return sigA, sigB, nil
+}
+
+// MakeSig converts a wallet.Sig to a [65]byte.
+func MakeSig(sig pwallet.Sig) ([65]byte, error) {
+	var b [65]byte
+	if len(sig) != len(b) {
+		return b, errors.Errorf("unexpected signature length %d, want %d", len(sig), len(b))
+	}
+	copy(b[:], sig)
+	return b, nil
+}
@@ -1,13 +1,15 @@
 package encoding
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/crypto/secp256k1"
 	bin "github.com/gagliardetto/binary"
 	"github.com/gagliardetto/solana-go"
 	"github.com/perun-network/perun-solana-backend/wallet"
 	"github.com/pkg/errors"
-	"perun.network/go-perun/channel"
 	pchannel "perun.network/go-perun/channel"
 )
 
@@ -75,24 +77,38 @@ func MakeChannelID(id [32]byte) ChannelID {
 	}
 }
 
-// Params represents the parameters of a channel on-chain.
+// ToWalletParticipant converts the on-chain participant to a wallet.Participant, which can be used to verify
+// signatures of the participant.
+func (p Participant) ToWalletParticipant() (*wallet.Participant, error) {
+	x, y := elliptic.Unmarshal(secp256k1.S256(), p.L2Pubkey[:]) //nolint:staticcheck
+	if x == nil || y == nil {
+		return nil, errors.New("invalid L2 public key")
+	}
+	pubKey := &ecdsa.PublicKey{
+		Curve: secp256k1.S256(),
+		X:     x,
+		Y:     y,
+	}
+	return wallet.NewParticipant(p.SolanaAddress, pubKey, p.CcAddress), nil
+}
+
+// Params represents the parameters of a channel on-chain. App, LedgerChannel and VirtualChannel are not part of the
+// original layout and only encoded for channels other than plain ledger channels.
 type Params struct {
 	A                 Participant
 	B                 Participant
 	Nonce             [32]byte
 	ChallengeDuration uint64
+	App               solana.PublicKey // The program ID of the channel app, the zero key for channels without app.
+	LedgerChannel     bool
+	VirtualChannel    bool // Virtual channels are funded by the locked funds of their ledger parents.
 }
 
-// MakeParams converts a pchannel.Params to a Params.
+// MakeParams converts a pchannel.Params to a Params. Ledger channels, sub-channels and virtual channels are
+// supported.
 func MakeParams(params pchannel.Params) (Params, error) {
-	if !params.LedgerChannel {
-		return Params{}, errors.New("expected ledger channel")
-	}
-	if params.VirtualChannel {
-		return Params{}, errors.New("expected non-virtual channel")
-	}
-	if !pchannel.IsNoApp(params.App) {
-		return Params{}, errors.New("expected no app")
+	if params.LedgerChannel && params.VirtualChannel {
+		return Params{}, errors.New("channel cannot be both ledger and virtual channel")
 	}
 	if len(params.Parts) != 2 { //nolint:gomnd
 		return Params{}, errors.New("expected exactly two participants")
@@ -115,21 +131,30 @@ func MakeParams(params pchannel.Params) (Params, error) {
 	if err != nil {
 		return Params{}, err
 	}
+	app, err := MakeApp(params.App)
+	if err != nil {
+		return Params{}, err
+	}
 	nonce := MakeNonce(params.Nonce)
 	return Params{
 		A:                 a,
 		B:                 b,
 		Nonce:             nonce,
 		ChallengeDuration: params.ChallengeDuration,
+		App:               app,
+		LedgerChannel:     params.LedgerChannel,
+		VirtualChannel:    params.VirtualChannel,
 	}, nil
 }
 
-// ChannelState represents the state of a channel on-chain.
+// ChannelState represents the state of a channel on-chain. AppData and the locked funds of Balances are not part of
+// the original layout and only encoded for channels that use them.
 type ChannelState struct {
 	ChannelID [32]byte
 	Balances  Balances
 	Version   uint64
 	Finalized bool
+	AppData   []byte // The encoded app data, empty for channels without app.
 }
 
 // MakeChannelState converts a pchannel.State to a ChannelState.
@@ -137,11 +162,9 @@ func MakeChannelState(state pchannel.State) (ChannelState, error) {
 	if err := state.Valid(); err != nil {
 		return ChannelState{}, err
 	}
-	if !channel.IsNoApp(state.App) {
-		return ChannelState{}, errors.New("expected NoApp")
-	}
-	if !channel.IsNoData(state.Data) {
-		return ChannelState{}, errors.New("expected NoData")
+	appData, err := MakeAppData(state.App, state.Data)
+	if err != nil {
+		return ChannelState{}, err
 	}
 	balances, err := MakeBalances(state.Allocation)
 	if err != nil {
@@ -154,6 +177,7 @@ func MakeChannelState(state pchannel.State) (ChannelState, error) {
 		Balances:  balances,
 		Version:   state.Version,
 		Finalized: state.IsFinal,
+		AppData:   appData,
 	}, nil
 }
 
@@ -162,6 +186,35 @@ type Balances struct {
 	Tokens []CrossAsset
 	BalA   []uint64
 	BalB   []uint64
+	Locked []SubAlloc // The funds locked in sub-channels.
+}
+
+// SubAlloc represents the funds locked in a sub-channel on-chain.
+type SubAlloc struct {
+	ID       [32]byte
+	Balances []uint64 // The locked balance of each token, in the order of Balances.Tokens.
+	IndexMap []uint16 // The index of each sub-channel or virtual channel participant in the parent channel.
+}
+
+// MakeSubAlloc converts a pchannel.SubAlloc to a SubAlloc.
+func MakeSubAlloc(subAlloc pchannel.SubAlloc) (SubAlloc, error) {
+	bals := make([]uint64, len(subAlloc.Bals))
+	for i, bal := range subAlloc.Bals {
+		balVal, err := MakeUint64(bal)
+		if err != nil {
+			return SubAlloc{}, err
+		}
+		bals[i] = balVal
+	}
+	indexMap := make([]uint16, len(subAlloc.IndexMap))
+	for i, idx := range subAlloc.IndexMap {
+		indexMap[i] = uint16(idx)
+	}
+	return SubAlloc{
+		ID:       subAlloc.ID,
+		Balances: bals,
+		IndexMap: indexMap,
+	}, nil
 }
 
 // MakeBalances converts a pchannel.Allocation to Balances.
@@ -169,9 +222,6 @@ func MakeBalances(alloc pchannel.Allocation) (Balances, error) {
 	if err := alloc.Valid(); err != nil {
 		return Balances{}, err
 	}
-	if len(alloc.Locked) != 0 {
-		return Balances{}, errors.New("expected no locked funds")
-	}
 	assets := alloc.Assets
 	tokens, err := MakeTokens(assets)
 	if err != nil {
@@ -210,10 +260,19 @@ func MakeBalances(alloc pchannel.Allocation) (Balances, error) {
 		balBPartVec = balPartVecs[1]
 	}
 
+	locked := make([]SubAlloc, len(alloc.Locked))
+	for i, subAlloc := range alloc.Locked {
+		locked[i], err = MakeSubAlloc(subAlloc)
+		if err != nil {
+			return Balances{}, err
+		}
+	}
+
 	return Balances{
 		BalA:   balAPartVec,
 		BalB:   balBPartVec,
 		Tokens: tokens,
+		Locked: locked,
 	}, nil
 }
 